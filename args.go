@@ -0,0 +1,350 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ParamKind is the type a command parameter is parsed as
+type ParamKind int
+
+// parameter kinds
+const (
+	ParamString   ParamKind = iota // a single word, or "quoted words"
+	ParamInt                       // whole number, +2 and -1 are allowed
+	ParamFloat                     // decimal number
+	ParamDuration                  // 1h2m3s, 2m, 30s, 30, 1:30 or 1:02:03
+	ParamUser                      // user mention or ID
+	ParamRole                      // role mention, ID or name
+	ParamChannel                   // channel mention or ID
+	ParamEnum                      // one of choices
+	ParamRest                      // the rest of the line
+)
+
+var paramKindNames = map[ParamKind]string{
+	ParamString:   "text",
+	ParamInt:      "number",
+	ParamFloat:    "decimal",
+	ParamDuration: "time",
+	ParamUser:     "user",
+	ParamRole:     "role",
+	ParamChannel:  "channel",
+	ParamRest:     "text",
+}
+
+// Param describes a single named command argument
+//	- optional params must come after all required params
+//	- ParamRest consumes everything left and must be last
+//	- choices are only used by ParamEnum and are case-insensitive
+//	- min/max are checked for ParamInt and ParamFloat if either is non-zero
+//	- pattern is a regex checked against ParamString and ParamRest values,
+//		it's compiled once by RegisterCommand
+type Param struct {
+	name     string
+	kind     ParamKind
+	help     string
+	optional bool
+	choices  []string
+	min      float64
+	max      float64
+	pattern  string
+	rx       *regexp.Regexp
+}
+
+// usage returns a short usage token such as <name:number> or [name]
+func (p Param) usage() string {
+	str := p.name
+	if p.kind == ParamEnum {
+		str += ":" + strings.Join(p.choices, "|")
+	} else if p.kind == ParamRest {
+		str += "..."
+	} else if p.kind != ParamString {
+		str += ":" + paramKindNames[p.kind]
+	}
+	if p.optional {
+		return "[" + str + "]"
+	}
+	return "<" + str + ">"
+}
+
+// ParamUsage returns a usage line for a list of params
+func ParamUsage(params []Param) string {
+	var list []string
+	for _, p := range params {
+		list = append(list, p.usage())
+	}
+	return strings.Join(list, " ")
+}
+
+// acceptsEmpty returns whether a command can be called without arguments
+func acceptsEmpty(cmd *Command) bool {
+	if cmd.emptyArg {
+		return true
+	}
+	if len(cmd.params) > 0 {
+		return cmd.params[0].optional
+	}
+	return false
+}
+
+type argToken struct {
+	val string
+	end int
+}
+
+// splits an argument string on whitespace, keeping "quoted words" together
+func tokenizeArgs(str string) []argToken {
+	var tokens []argToken
+	i := 0
+	for i < len(str) {
+		for i < len(str) && (str[i] == ' ' || str[i] == '\t' || str[i] == '\n') {
+			i++
+		}
+		if i >= len(str) {
+			break
+		}
+
+		if str[i] == '"' {
+			end := strings.IndexByte(str[i+1:], '"')
+			if end != -1 {
+				tokens = append(tokens, argToken{val: str[i+1 : i+1+end], end: i + end + 2})
+				i += end + 2
+				continue
+			}
+		}
+
+		start := i
+		for i < len(str) && str[i] != ' ' && str[i] != '\t' && str[i] != '\n' {
+			i++
+		}
+		tokens = append(tokens, argToken{val: str[start:i], end: i})
+	}
+	return tokens
+}
+
+// ParseParams parses an argument string according to a list of params
+//	returned map is keyed by param name, optional params that
+//	weren't given are left out
+func ParseParams(ca CommandArgs, params []Param, args string) (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+	tokens := tokenizeArgs(args)
+
+	t := 0
+	for _, p := range params {
+		if t >= len(tokens) {
+			if p.optional {
+				continue
+			}
+			return nil, errors.New(ca.T("args.missing", "param", p.name))
+		}
+
+		raw := tokens[t].val
+		if p.kind == ParamRest {
+			start := 0
+			if t > 0 {
+				start = tokens[t-1].end
+			}
+			raw = strings.TrimSpace(args[start:])
+			t = len(tokens)
+		} else {
+			t++
+		}
+
+		val, err := parseParam(ca, p, raw)
+		if err != nil {
+			return nil, errors.New(ca.T("args.invalid", "param", p.name, "err", err))
+		}
+		out[p.name] = val
+	}
+
+	if t < len(tokens) {
+		return nil, errors.New(ca.T("args.toomany"))
+	}
+
+	return out, nil
+}
+
+var durationRx = regexp.MustCompile(`^(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s?)?$`)
+var clockTimeRx = regexp.MustCompile(`^(?:(\d+):)?(\d+):(\d{1,2})$`)
+var userMentionRx = regexp.MustCompile(`^<@!?(\d+)>$`)
+var roleMentionRx = regexp.MustCompile(`^<@&(\d+)>$`)
+var channelMentionRx = regexp.MustCompile(`^<#(\d+)>$`)
+var snowflakeRx = regexp.MustCompile(`^\d{15,21}$`)
+
+// ParseDuration parses a strict duration string
+//	accepts 1h2m3s, 2m, 30s, 30, 1:30 and 1:02:03
+func ParseDuration(str string) (time.Duration, error) {
+	var groups []string
+	if m := clockTimeRx.FindStringSubmatch(str); m != nil {
+		groups = m
+	} else if m := durationRx.FindStringSubmatch(str); m != nil && str != "" {
+		groups = m
+	} else {
+		return 0, errors.New("not a valid time")
+	}
+
+	total := time.Duration(0)
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	for i, g := range groups[1:] {
+		if g == "" {
+			continue
+		}
+		n, err := strconv.Atoi(g)
+		if err != nil {
+			return 0, errors.New("not a valid time")
+		}
+		total += time.Duration(n) * units[i]
+	}
+	return total, nil
+}
+
+func parseParam(ca CommandArgs, p Param, raw string) (interface{}, error) {
+	switch p.kind {
+	case ParamInt:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, errors.New(ca.T("args.int"))
+		}
+		if err := checkRange(ca, p, float64(n)); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case ParamFloat:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, errors.New(ca.T("args.number"))
+		}
+		if err := checkRange(ca, p, f); err != nil {
+			return nil, err
+		}
+		return f, nil
+	case ParamDuration:
+		d, err := ParseDuration(strings.ToLower(raw))
+		if err != nil {
+			return nil, errors.New(ca.T("args.time"))
+		}
+		return d, nil
+	case ParamUser:
+		id := raw
+		if m := userMentionRx.FindStringSubmatch(raw); m != nil {
+			id = m[1]
+		} else if !snowflakeRx.MatchString(raw) {
+			return nil, errors.New(ca.T("args.user"))
+		}
+		ok, user := CacheUser(ca.sess, id)
+		if !ok {
+			return nil, errors.New(ca.T("args.user.notfound"))
+		}
+		return user, nil
+	case ParamRole:
+		gid := ca.guildID()
+		if gid == "" {
+			return nil, errors.New(ca.T("args.role.dm"))
+		}
+		id := ""
+		if m := roleMentionRx.FindStringSubmatch(raw); m != nil {
+			id = m[1]
+		} else if snowflakeRx.MatchString(raw) {
+			id = raw
+		}
+		if id != "" {
+			role, err := ca.sess.State().Role(gid, id)
+			if err != nil {
+				return nil, errors.New(ca.T("args.role.notfound"))
+			}
+			return role, nil
+		}
+		role, err := GetRole(ca.sess, gid, raw)
+		if err != nil {
+			return nil, errors.New(ca.T("args.role.notfound"))
+		}
+		return role, nil
+	case ParamChannel:
+		id := raw
+		if m := channelMentionRx.FindStringSubmatch(raw); m != nil {
+			id = m[1]
+		} else if !snowflakeRx.MatchString(raw) {
+			return nil, errors.New(ca.T("args.channel"))
+		}
+		ch, err := lookupChannel(ca.sess, id)
+		if err != nil {
+			return nil, errors.New(ca.T("args.channel.notfound"))
+		}
+		return ch, nil
+	case ParamEnum:
+		for _, c := range p.choices {
+			if strings.EqualFold(c, raw) {
+				return c, nil
+			}
+		}
+		return nil, errors.New(ca.T("args.choices", "choices", strings.Join(p.choices, ", ")))
+	default: // ParamString, ParamRest
+		if p.pattern != "" && !p.rx.MatchString(raw) {
+			return nil, errors.New(ca.T("args.format"))
+		}
+		return raw, nil
+	}
+}
+
+func checkRange(ca CommandArgs, p Param, n float64) error {
+	if p.min == 0 && p.max == 0 {
+		return nil
+	}
+	if n < p.min || n > p.max {
+		return errors.New(ca.T("args.range", "min", p.min, "max", p.max))
+	}
+	return nil
+}
+
+// Has returns whether a param was given
+func (ca CommandArgs) Has(name string) bool {
+	_, ok := ca.params[name]
+	return ok
+}
+
+// Str returns a parsed string, enum or rest param
+func (ca CommandArgs) Str(name string) string {
+	s, _ := ca.params[name].(string)
+	return s
+}
+
+// Int returns a parsed int param
+func (ca CommandArgs) Int(name string) int {
+	n, _ := ca.params[name].(int)
+	return n
+}
+
+// Float returns a parsed float param
+func (ca CommandArgs) Float(name string) float64 {
+	f, _ := ca.params[name].(float64)
+	return f
+}
+
+// Duration returns a parsed duration param
+func (ca CommandArgs) Duration(name string) time.Duration {
+	d, _ := ca.params[name].(time.Duration)
+	return d
+}
+
+// User returns a parsed user param
+func (ca CommandArgs) User(name string) *discordgo.User {
+	u, _ := ca.params[name].(*discordgo.User)
+	return u
+}
+
+// Role returns a parsed role param
+func (ca CommandArgs) Role(name string) *discordgo.Role {
+	r, _ := ca.params[name].(*discordgo.Role)
+	return r
+}
+
+// Channel returns a parsed channel param
+func (ca CommandArgs) Channel(name string) *discordgo.Channel {
+	c, _ := ca.params[name].(*discordgo.Channel)
+	return c
+}
@@ -1,62 +1,120 @@
 package main
 
 import (
-	"fmt"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-func nextReaction(sess *discordgo.Session) chan *discordgo.MessageReactionAdd {
-	ch := make(chan *discordgo.MessageReactionAdd)
-	sess.AddHandlerOnce(func(_ *discordgo.Session, ev *discordgo.MessageReactionAdd) {
-		ch <- ev
-	})
-	return ch
-}
+var buttonLog = NewLogger("buttons")
 
 // ButtonHandler is a callback function for when a button is pressed
 type ButtonHandler func(*ButtonizedMessage, *discordgo.Member)
 
 // ButtonizedMessage contains all info about a buttonized message
-//	Listen must be called after all handlers are set up
+//	Listen must be called after all handlers are set up, and always called,
+//	closeAllButtons waits for every message ButtonizeMessage returned
 //	send an int on the Close channel to stop listening
+//	if Idle is set, listening stops after that long without a button press
+//	and OnIdle is called, which removes the buttons if it's nil
 type ButtonizedMessage struct {
 	Msg      *discordgo.Message
-	Sess     *discordgo.Session
+	Sess     Transport
 	handlers map[string]ButtonHandler
 	Close    chan bool
+	Idle     time.Duration
+	OnIdle   func(*ButtonizedMessage)
 }
 
+// messages being listened to, so they can all be closed on shutdown
+var listeningMutex sync.Mutex
+var listening = make(map[*ButtonizedMessage]bool)
+var listeningWG sync.WaitGroup
+
 // Listen for reaction events
 func (bm *ButtonizedMessage) Listen() {
+	reactions, stop := bm.Sess.MessageReactions(bm.Msg.ID)
+	defer stop()
+	defer func() {
+		listeningMutex.Lock()
+		delete(listening, bm)
+		listeningMutex.Unlock()
+		listeningWG.Done()
+	}()
+	defer recoverGoroutine(bm.Sess, "ButtonizedMessage.Listen", bm.Msg.GuildID, bm.Msg.ChannelID)
+
+	// nil never fires, so messages without Idle listen until closed
+	var idle <-chan time.Time
+	var timer *time.Timer
+	if bm.Idle > 0 {
+		timer = time.NewTimer(bm.Idle)
+		defer timer.Stop()
+		idle = timer.C
+	}
+
 	for {
 		select {
-		case ev := <-nextReaction(bm.Sess):
-			if ev.UserID != bm.Sess.State.User.ID {
-				if ev.MessageID == bm.Msg.ID {
-					emoji := ev.Emoji.Name
-
-					// will silently fail if bot doesn't have permissions
-					bm.Sess.MessageReactionRemove(bm.Msg.ChannelID, bm.Msg.ID, emoji, ev.UserID)
-
-					handler, ok := bm.handlers[emoji]
-					if ok {
-						mem, err := bm.Sess.State.Member(ev.GuildID, ev.UserID)
-						if err != nil {
-							fmt.Println("couldn't get member for button event")
-							handler(bm, nil)
-						} else {
-							handler(bm, mem)
-						}
+		case ev := <-reactions:
+			if ev.UserID != bm.Sess.State().User.ID {
+				emoji := ev.Emoji.Name
+
+				// will silently fail if bot doesn't have permissions
+				bm.Sess.MessageReactionRemove(bm.Msg.ChannelID, bm.Msg.ID, emoji, ev.UserID)
+
+				handler, ok := bm.handlers[emoji]
+				if ok {
+					mem, err := lookupMember(bm.Sess, ev.GuildID, ev.UserID)
+					if err != nil {
+						buttonLog.Warn("couldn't get member for button event", "guild", ev.GuildID, "user", ev.UserID, "err", err)
+						mem = nil
+					}
+					bm.runHandler(handler, mem)
+					if timer != nil {
+						resetTimer(timer, bm.Idle)
 					}
 				}
 			}
+		case <-idle:
+			if bm.OnIdle != nil {
+				bm.OnIdle(bm)
+			} else {
+				bm.Sess.MessageReactionsRemoveAll(bm.Msg.ChannelID, bm.Msg.ID)
+			}
+			return
 		case <-bm.Close:
 			return
 		}
 	}
 }
 
+// restarts a timer that may or may not have fired
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
+
+// closeAllButtons stops every ButtonizedMessage listening and waits for them to finish
+func closeAllButtons() {
+	listeningMutex.Lock()
+	for bm := range listening {
+		bm.Close <- true
+	}
+	listeningMutex.Unlock()
+	listeningWG.Wait()
+}
+
+// runs a button handler so a panic in it doesn't stop the message listening
+func (bm *ButtonizedMessage) runHandler(handler ButtonHandler, mem *discordgo.Member) {
+	defer recoverGoroutine(bm.Sess, "button handler", bm.Msg.GuildID, bm.Msg.ChannelID)
+	handler(bm, mem)
+}
+
 // AddHandler for an emoji
 func (bm *ButtonizedMessage) AddHandler(emoji string, handler ButtonHandler) {
 	bm.Sess.MessageReactionAdd(bm.Msg.ChannelID, bm.Msg.ID, emoji)
@@ -64,12 +122,19 @@ func (bm *ButtonizedMessage) AddHandler(emoji string, handler ButtonHandler) {
 }
 
 // ButtonizeMessage and return ButtonizedMessage
-func ButtonizeMessage(sess *discordgo.Session, msg *discordgo.Message) *ButtonizedMessage {
+func ButtonizeMessage(sess Transport, msg *discordgo.Message) *ButtonizedMessage {
 	sess.MessageReactionsRemoveAll(msg.ChannelID, msg.ID)
 	bm := &ButtonizedMessage{}
 	bm.Msg = msg
 	bm.Sess = sess
 	bm.Close = make(chan bool, 10)
 	bm.handlers = make(map[string]ButtonHandler)
+
+	// registered here instead of in Listen, which is usually in a goroutine,
+	// so closeAllButtons can't miss a message that hasn't started listening yet
+	listeningMutex.Lock()
+	listening[bm] = true
+	listeningWG.Add(1)
+	listeningMutex.Unlock()
 	return bm
 }
@@ -1,15 +1,136 @@
 package main
 
-import "github.com/bwmarrin/discordgo"
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
 
-// TO DO: what if role ID changes while bot is running?
-var roleCache = make(map[string]map[string]string)
+	"github.com/bwmarrin/discordgo"
+)
+
+// lruCache is a size limited cache with expiring entries, safe for concurrent use
+//	the least recently used entry is dropped when it's full
+type lruCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	hits    int
+	misses  int
+}
+
+type cacheEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// cacheStats is shown by the stats command
+type cacheStats struct {
+	Entries int
+	Hits    int
+	Misses  int
+}
+
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{ttl: ttl, size: size, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	ent := el.Value.(*cacheEntry)
+	if time.Now().After(ent.expires) {
+		c.removeElement(el)
+		c.misses++
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	c.hits++
+	return ent.value, true
+}
+
+func (c *lruCache) set(key string, value interface{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+	if el, ok := c.entries[key]; ok {
+		el.Value = &cacheEntry{key: key, value: value, expires: expires}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// update replaces an entry only if it's cached, keeping its expiry
+func (c *lruCache) update(key string, value interface{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry).value = value
+	}
+}
+
+func (c *lruCache) remove(key string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// removePrefix removes every entry with a key starting with prefix
+func (c *lruCache) removePrefix(prefix string) {
+	c.Lock()
+	defer c.Unlock()
+
+	for key, el := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(el)
+		}
+	}
+}
+
+// must be called with c locked
+func (c *lruCache) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+func (c *lruCache) stats() cacheStats {
+	c.Lock()
+	defer c.Unlock()
+	return cacheStats{Entries: c.order.Len(), Hits: c.hits, Misses: c.misses}
+}
+
+// role IDs by guild and lowercase role name, as "gid/name"
+//	cleared for a guild when any of its roles change, since a rename
+//	or delete can change which role a name means
+var roleCache = newLRUCache(5000, time.Hour)
+
+func roleCacheKey(gid string, roleName string) string {
+	return gid + "/" + strings.ToLower(roleName)
+}
 
 // CacheRole caches and returns a guild role by ID
-func CacheRole(sess *discordgo.Session, gid string, roleName string) (bool, string) {
-	roleID, ok := roleCache[gid][roleName]
-	if ok {
-		return true, roleID
+func CacheRole(sess Transport, gid string, roleName string) (bool, string) {
+	key := roleCacheKey(gid, roleName)
+	if roleID, ok := roleCache.get(key); ok {
+		return true, roleID.(string)
 	}
 
 	role, err := GetRole(sess, gid, roleName)
@@ -17,22 +138,17 @@ func CacheRole(sess *discordgo.Session, gid string, roleName string) (bool, stri
 		return false, ""
 	}
 
-	_, ok = roleCache[gid]
-	if !ok {
-		roleCache[gid] = make(map[string]string)
-	}
-
-	roleCache[gid][roleName] = role.ID
+	roleCache.set(key, role.ID)
 	return true, role.ID
 }
 
-var userCache = make(map[string]*discordgo.User)
+// users by ID, kept up to date by user and member update events
+var userCache = newLRUCache(10000, 30*time.Minute)
 
 // CacheUser caches and returns a user by ID
-func CacheUser(sess *discordgo.Session, uid string) (bool, *discordgo.User) {
-	user, ok := userCache[uid]
-	if ok {
-		return true, user
+func CacheUser(sess Transport, uid string) (bool, *discordgo.User) {
+	if user, ok := userCache.get(uid); ok {
+		return true, user.(*discordgo.User)
 	}
 
 	user, err := sess.User(uid)
@@ -40,6 +156,30 @@ func CacheUser(sess *discordgo.Session, uid string) (bool, *discordgo.User) {
 		return false, nil
 	}
 
-	userCache[uid] = user
+	userCache.set(uid, user)
 	return true, user
 }
+
+func guildRoleCreate(sess *discordgo.Session, r *discordgo.GuildRoleCreate) {
+	roleCache.removePrefix(r.GuildID + "/")
+}
+
+func guildRoleUpdate(sess *discordgo.Session, r *discordgo.GuildRoleUpdate) {
+	roleCache.removePrefix(r.GuildID + "/")
+}
+
+func guildRoleDelete(sess *discordgo.Session, r *discordgo.GuildRoleDelete) {
+	roleCache.removePrefix(r.GuildID + "/")
+}
+
+func guildMemberUpdate(sess *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+	if m.Member != nil && m.User != nil {
+		userCache.update(m.User.ID, m.User)
+	}
+}
+
+func userUpdate(sess *discordgo.Session, u *discordgo.UserUpdate) {
+	if u.User != nil {
+		userCache.update(u.ID, u.User)
+	}
+}
@@ -3,12 +3,15 @@ package main
 import (
 	"fmt"
 	"regexp"
-	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+var cmdLog = NewLogger("commands")
+
 // CommandList of chat commands
 var CommandList []Command
 
@@ -18,26 +21,154 @@ var CommandList []Command
 //	- first line of help string is used as a short description
 //	- %P is replaced with first bot prefix
 //	- ^ is replaced with ` so literals can be used for newlines
+//	- params are parsed and validated before callback is called,
+//		and a usage line is added to help (see Param)
+//	- errorTimeout deletes argument errors after that many seconds
+//	- subcommands are matched against the first argument and have
+//		their own help, access rules and params on top of the parent's
+//	- callback can be nil if a command only has subcommands
+//	- inChannel limits a command to channels where it returns true,
+//		the command is silently ignored elsewhere
+//	- cooldowns are checked after params are parsed (see Cooldown)
+//	- middleware runs around the callback after any global middleware (see Middleware)
+//	- tier is the minimum privilege tier needed to use the command (see PrivTier)
+//	- regexes are matched against whole messages, even without a prefix (see RegexRoute)
+//	- aliases must be unique, RegisterCommand panics on conflicts
+//	- module is the module the command belongs to, subcommands
+//		are always in their parent's module (see ModuleEnabled)
 type Command struct {
-	aliases   []string
-	regexes   []string
-	callback  func(CommandArgs) bool
-	help      string
-	emptyArg  bool
-	hidden    bool
-	roles     []string
-	ownerOnly bool
-	noDM      bool
+	aliases      []string
+	regexes      []RegexRoute
+	callback     func(CommandArgs) bool
+	help         string
+	params       []Param
+	subcommands  []Command
+	emptyArg     bool
+	hidden       bool
+	roles        []string
+	noDM         bool
+	tier         PrivTier
+	errorTimeout int
+	inChannel    func(CommandArgs) bool
+	cooldowns    []Cooldown
+	middleware   []Middleware
+	module       string
+	path         string
+}
+
+// RegexRoute matches messages to a command by regex instead of alias
+//	- aliases are matched at priority 0, routes with a higher priority
+//		run before them and can consume messages, lower ones only run
+//		if the message wasn't an alias, routes at 0 run before aliases
+//	- routes with the same priority run in the order they were registered
+//	- inChannel limits the route to channels where it returns true,
+//		on top of the command's own inChannel
+type RegexRoute struct {
+	pattern   string
+	priority  int
+	inChannel func(CommandArgs) bool
+	rx        *regexp.Regexp
+}
+
+// a command's regex route, sorted by priority in regexRoutes
+type regexRoute struct {
+	cmd   int
+	route *RegexRoute
 }
 
+var regexRoutes []regexRoute
+
 // RegisterCommand to the bot
+//	panics if an alias is already taken
 func RegisterCommand(cmd Command) {
+	for _, a := range cmd.aliases {
+		if c := findCommand(a); c != nil {
+			panic(fmt.Sprintf("alias %q of command %q is already used by %q", a, cmd.aliases[0], c.path))
+		}
+	}
+	prepareCommand(&cmd, "")
 	CommandList = append(CommandList, cmd)
+
+	i := len(CommandList) - 1
+	for n := range CommandList[i].regexes {
+		regexRoutes = append(regexRoutes, regexRoute{i, &CommandList[i].regexes[n]})
+	}
+	sort.SliceStable(regexRoutes, func(a, b int) bool {
+		return regexRoutes[a].route.priority > regexRoutes[b].route.priority
+	})
+}
+
+// prepareCommand fills in the full name of a command and its subcommands, ie "clock create",
+// compiles their regexes and checks for alias conflicts between subcommands
+func prepareCommand(cmd *Command, parent string) {
+	cmd.path = strings.TrimSpace(parent + " " + cmd.aliases[0])
+	if cmd.module == "" {
+		cmd.module = coreModule
+	}
+	if !isModule(cmd.module) {
+		panic(fmt.Sprintf("command %q is in unknown module %q", cmd.path, cmd.module))
+	}
+
+	for i := range cmd.regexes {
+		cmd.regexes[i].rx = regexp.MustCompile(cmd.regexes[i].pattern)
+	}
+	for i := range cmd.params {
+		if cmd.params[i].pattern != "" {
+			cmd.params[i].rx = regexp.MustCompile(cmd.params[i].pattern)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, a := range cmd.aliases {
+		if a != strings.ToLower(a) {
+			panic(fmt.Sprintf("alias %q of command %q must be lowercase", a, cmd.path))
+		}
+		if seen[a] {
+			panic(fmt.Sprintf("alias %q is repeated in command %q", a, cmd.path))
+		}
+		seen[a] = true
+	}
+
+	subs := make(map[string]string)
+	for i := range cmd.subcommands {
+		cmd.subcommands[i].module = cmd.module
+		prepareCommand(&cmd.subcommands[i], cmd.path)
+		for _, a := range cmd.subcommands[i].aliases {
+			if other, ok := subs[a]; ok {
+				panic(fmt.Sprintf("alias %q of subcommand %q is already used by %q", a, cmd.subcommands[i].path, other))
+			}
+			subs[a] = cmd.subcommands[i].path
+		}
+	}
+}
+
+// findCommand returns the top level command with a matching alias
+func findCommand(name string) *Command {
+	for i, cmd := range CommandList {
+		for _, a := range cmd.aliases {
+			if a == name {
+				return &CommandList[i]
+			}
+		}
+	}
+	return nil
+}
+
+// findSubcommand returns the subcommand with a matching alias
+func findSubcommand(cmd *Command, name string) *Command {
+	for i, sub := range cmd.subcommands {
+		for _, a := range sub.aliases {
+			if a == name {
+				return &cmd.subcommands[i]
+			}
+		}
+	}
+	return nil
 }
 
 // CommandArgs to be passed around easily
 type CommandArgs struct {
-	sess    *discordgo.Session
+	sess    Transport
 	msg     *discordgo.Message
 	chO     string
 	usrO    string
@@ -46,23 +177,66 @@ type CommandArgs struct {
 	args    string
 	content string
 	isRegex bool
+	params  map[string]interface{}
+
+	// set for slash commands, see interactionReply
+	reply     *interactionReply
+	ephemeral bool
+
+	// set by runCallback for middleware timing the command
+	started time.Time
+}
+
+// guildID returns the guild the command was called in, if any
+func (ca CommandArgs) guildID() string {
+	if ca.msg == nil {
+		return ""
+	}
+	return ca.msg.GuildID
 }
 
 // HasAccess checks if user has access to command
-func HasAccess(sess *discordgo.Session, cmd Command, msg *discordgo.Message) bool {
-	if cmd.ownerOnly && msg.Author.ID == Config.OwnerID {
+//	- users below the command's tier never have access
+//	- owners bypass noDM, roles and guild rules
+//	- bot admins bypass roles and guild rules
+//	- guild admins always hold the botadmin node, so a rule on it or on a
+//		botadmin command can't lock them out, other nodes and rules apply to them
+//	- a guild rule for the command's own node overrides its roles
+func HasAccess(sess Transport, cmd Command, msg *discordgo.Message) bool {
+	tier := UserTier(sess, msg)
+	if tier < cmd.tier {
+		return false
+	}
+	if tier >= TierOwner {
 		return true
 	}
 	if cmd.noDM && msg.Member == nil {
 		return false
 	}
+	if tier >= TierBotAdmin {
+		return true
+	}
+	if tier >= TierGuildAdmin {
+		for _, node := range cmd.roles {
+			if node == "botadmin" {
+				return true
+			}
+		}
+	}
+
+	if msg.Member != nil {
+		if rule, ok := guildPermRule(msg.GuildID, commandNode(cmd)); ok {
+			return ruleAllows(sess, rule, msg.Member, msg.ChannelID)
+		}
+	}
+
 	if len(cmd.roles) > 0 {
 		if msg.Member == nil {
 			return false
 		}
 
-		for _, roleName := range cmd.roles {
-			if HasRole(sess, msg.Member, roleName) {
+		for _, node := range cmd.roles {
+			if HasPermission(sess, msg.Member, msg.ChannelID, node) {
 				return true
 			}
 		}
@@ -71,8 +245,29 @@ func HasAccess(sess *discordgo.Session, cmd Command, msg *discordgo.Message) boo
 	return true
 }
 
+// stripPrefix removes a guild prefix or bot mention from the start of a message
+//	returns the remaining content and whether a prefix was found
+func stripPrefix(sess Transport, m *discordgo.Message) (string, bool) {
+	content := m.Content
+
+	botID := sess.State().User.ID
+	for _, mention := range []string{"<@" + botID + ">", "<@!" + botID + ">"} {
+		if strings.HasPrefix(content, mention) {
+			return strings.TrimSpace(content[len(mention):]), true
+		}
+	}
+
+	lower := strings.ToLower(content)
+	for _, p := range sortPrefixes(GuildPrefixes(m.GuildID)) {
+		if len(content) > len(p) && strings.HasPrefix(lower, strings.ToLower(p)) {
+			return content[len(p):], true
+		}
+	}
+	return content, false
+}
+
 // HandleCommand on message event
-func HandleCommand(sess *discordgo.Session, m *discordgo.Message) {
+func HandleCommand(sess Transport, m *discordgo.Message) {
 	// fix discordgo bug
 	if m.Member != nil && m.Member.User == nil {
 		m.Member.User = m.Author
@@ -81,111 +276,192 @@ func HandleCommand(sess *discordgo.Session, m *discordgo.Message) {
 		m.Member.GuildID = m.GuildID
 	}
 
-	defer func() {
-		if r := recover(); r != nil {
-			// get first 15 lines of stack
-			buf := make([]byte, 1024)
-			runtime.Stack(buf, false)
-			str := string(buf)
-			lines := strings.Split(str, "\n")
-			stack := strings.Join(lines[:15], "\n")
-
-			fmt.Println("<Recovered panic in HandleCommand>\n", stack)
+	defer recoverPanic(CommandArgs{sess: sess, msg: m})
 
-			if Config.SendErrors {
-				ch, err := GetDMChannel(sess, Config.OwnerID)
-				if err != nil {
-					fmt.Println("error DMing owner panic log", err)
-					return
-				}
-				stack = strings.Replace(stack, "	", ">", -1)
-				SendReply(CommandArgs{sess: sess, chO: ch.ID}, fmt.Sprintf("`<Recovered panic in HandleCommand>`\n```%s```", ClampStr(stack, 1957)))
-			}
-		}
-	}()
+	content, foundPrefix := stripPrefix(sess, m)
+	if !GuildPrefixOptional(m.GuildID) && !foundPrefix {
+		return
+	}
 
-	split := strings.SplitN(m.Content, " ", 2)
+	split := strings.SplitN(content, " ", 2)
 	mname := strings.ToLower(split[0])
 
-	foundPrefix := false
-	if len(mname) > 1 {
-		for _, p := range Config.Prefixes {
-			if string(mname[0]) == p {
-				mname = mname[1:]
-				foundPrefix = true
-				break
-			}
+	margs := ""
+	if len(split) > 1 {
+		margs = split[1]
+	}
+
+	margs = strings.TrimSpace(margs)
+
+	// regexes before aliases first in case they need to consume
+	n := 0
+	for ; n < len(regexRoutes) && regexRoutes[n].route.priority >= 0; n++ {
+		if runRegexRoute(sess, m, regexRoutes[n], mname, margs) {
+			return
 		}
 	}
 
-	if !Config.PrefixOptional && !foundPrefix {
+	if cmd := findCommand(mname); cmd != nil {
+		if HasAccess(sess, *cmd, m) && ModuleEnabled(m.GuildID, m.ChannelID, cmd.module) {
+			dispatchCommand(CommandArgs{sess: sess, msg: m, content: m.Content, alias: mname}, *cmd, margs)
+		}
 		return
 	}
 
-	margs := ""
-	if len(split) > 1 {
-		margs = split[1]
+	for ; n < len(regexRoutes); n++ {
+		if runRegexRoute(sess, m, regexRoutes[n], mname, margs) {
+			return
+		}
 	}
 
-	margs = strings.TrimSpace(margs)
+	// without a prefix this is probably just chat
+	if foundPrefix {
+		suggestUnknown(CommandArgs{sess: sess, msg: m, content: m.Content}, mname)
+	}
+}
 
-	// run regex first in case it needs to consume
-	for _, cmd := range CommandList {
-		for _, r := range cmd.regexes {
-			if regexp.MustCompile(r).MatchString(m.Content) {
-				if !HasAccess(sess, cmd, m) {
-					continue
-				}
+// runRegexRoute runs a regex route's command if the route matches the message
+//	returns whether the message was consumed
+func runRegexRoute(sess Transport, m *discordgo.Message, rr regexRoute, mname string, margs string) bool {
+	cmd := &CommandList[rr.cmd]
+	if !ModuleEnabled(m.GuildID, m.ChannelID, cmd.module) {
+		return false
+	}
+	if !rr.route.rx.MatchString(m.Content) || !HasAccess(sess, *cmd, m) {
+		return false
+	}
 
-				// no alias
-				shouldReturn := cmd.callback(CommandArgs{isRegex: true, sess: sess, msg: m, args: margs, content: m.Content, alias: mname, cmd: &cmd})
-				if shouldReturn {
-					return
-				}
+	// no alias
+	ca := CommandArgs{isRegex: true, sess: sess, msg: m, args: margs, content: m.Content, alias: mname, cmd: cmd}
+	if cmd.inChannel != nil && !cmd.inChannel(ca) {
+		return false
+	}
+	if rr.route.inChannel != nil && !rr.route.inChannel(ca) {
+		return false
+	}
+	return runCallback(ca, cmd)
+}
+
+// dispatchCommand descends into subcommands, parses params and runs the callback
+func dispatchCommand(ca CommandArgs, cmd Command, args string) {
+	if cmd.inChannel != nil && !cmd.inChannel(ca) {
+		return
+	}
+
+	if len(cmd.subcommands) > 0 && args != "" {
+		split := strings.SplitN(args, " ", 2)
+		sub := findSubcommand(&cmd, strings.ToLower(split[0]))
+		if sub != nil {
+			if !HasAccess(ca.sess, *sub, ca.msg) {
+				return
 			}
+
+			subargs := ""
+			if len(split) > 1 {
+				subargs = strings.TrimSpace(split[1])
+			}
+			dispatchCommand(ca, *sub, subargs)
+			return
 		}
 	}
 
-	for _, cmd := range CommandList {
-		for _, a := range cmd.aliases {
-			if a == mname {
-				if !HasAccess(sess, cmd, m) {
-					continue
-				}
+	if cmd.callback == nil || (args == "" && !acceptsEmpty(&cmd)) {
+		ShowHelp(ca, cmd)
+		return
+	}
 
-				if margs == "" && !cmd.emptyArg {
-					ShowHelp(CommandArgs{sess: sess, msg: m}, cmd)
-					return
-				}
-				cmd.callback(CommandArgs{sess: sess, msg: m, args: margs, content: m.Content, alias: mname, cmd: &cmd})
-				return
+	ca.args = args
+	ca.cmd = &cmd
+
+	if len(cmd.params) > 0 {
+		params, err := ParseParams(ca, cmd.params, args)
+		if err != nil {
+			usage := fmt.Sprintf("%s\n%s", err, ca.T("help.usage", "command", cmd.path, "usage", ParamUsage(cmd.params)))
+			if cmd.errorTimeout > 0 {
+				SendErrorTemp(ca, usage, cmd.errorTimeout)
+			} else {
+				SendError(ca, usage)
 			}
+			return
 		}
+		ca.params = params
 	}
+
+	runCallback(ca, &cmd)
+}
+
+// FindCommand resolves a command path like "clock create" to a command
+func FindCommand(ca CommandArgs, path string) (*Command, bool) {
+	fields := strings.Fields(strings.ToLower(path))
+	if len(fields) < 1 {
+		return nil, false
+	}
+
+	found := findCommand(fields[0])
+	if found == nil || !HasAccess(ca.sess, *found, ca.msg) || !commandEnabled(ca, *found) {
+		return nil, false
+	}
+
+	for _, f := range fields[1:] {
+		sub := findSubcommand(found, f)
+		if sub == nil || !HasAccess(ca.sess, *sub, ca.msg) {
+			return nil, false
+		}
+		found = sub
+	}
+	return found, true
+}
+
+// short description of a command from the first line of its help
+func shortHelp(cmd Command, gid string) string {
+	return strings.Split(formatTokens(commandHelp(cmd, gid), gid), "\n")[0]
+}
+
+// lists accessible subcommands as an indented tree
+func subcommandTree(ca CommandArgs, cmd Command, indent string) []string {
+	var list []string
+	for _, sub := range cmd.subcommands {
+		if sub.hidden || !HasAccess(ca.sess, sub, ca.msg) {
+			continue
+		}
+		list = append(list, fmt.Sprintf("%s└ %s - %s", indent, sub.aliases[0], shortHelp(sub, ca.guildID())))
+		list = append(list, subcommandTree(ca, sub, indent+"  ")...)
+	}
+	return list
 }
 
 var helpColour = 0x00cc00
 
 // ShowHelp posts a help embed for cmd
 func ShowHelp(ca CommandArgs, cmd Command) {
-	help := formatTokens(cmd.help)
+	gid := ca.guildID()
+	prefix := GuildPrefix(gid)
+	help := formatTokens(commandHelp(cmd, gid), gid)
 	help = strings.Replace(help, "\t", "", -1)
+	if len(cmd.params) > 0 {
+		help += "\n\n" + formatTokens(ca.T("help.usage", "command", cmd.path, "usage", ParamUsage(cmd.params)), gid)
+		for _, p := range cmd.params {
+			if ph := paramHelp(cmd, p, gid); ph != "" {
+				help += fmt.Sprintf("\n`%s` - %s", p.name, formatTokens(ph, gid))
+			}
+		}
+	}
+	if tree := subcommandTree(ca, cmd, ""); len(tree) > 0 {
+		help += fmt.Sprintf("\n\n%s\n```%s```", ca.T("help.subcommands"), strings.Join(tree, "\n"))
+		help += formatTokens(ca.T("help.more", "command", cmd.path), gid)
+	}
+	parent := strings.TrimSuffix(cmd.path, cmd.aliases[0])
 	footer := ""
 	if len(cmd.aliases) > 1 {
-		footer = "other aliases: "
-		for i, v := range cmd.aliases {
-			if i == 0 {
-				continue
-			}
-			footer += fmt.Sprintf("%s%s", Config.Prefixes[0], v)
-			if i != len(cmd.aliases)-1 {
-				footer += ", "
-			}
+		var aliases []string
+		for _, v := range cmd.aliases[1:] {
+			aliases = append(aliases, fmt.Sprintf("%s%s%s", prefix, parent, v))
 		}
+		footer = ca.T("help.aliases", "aliases", strings.Join(aliases, ", "))
 	}
 
 	QuickEmbed(ca, QEmbed{
-		title:   fmt.Sprintf("command help: %s%s", Config.Prefixes[0], cmd.aliases[0]),
+		title:   ca.T("help.title", "command", prefix+cmd.path),
 		content: help,
 		footer:  footer,
 		colour:  helpColour,
@@ -194,51 +470,52 @@ func ShowHelp(ca CommandArgs, cmd Command) {
 
 func init() {
 	RegisterCommand(Command{
-		aliases:  []string{"help"},
-		hidden:   true,
-		emptyArg: true,
-		help:     ":egg:",
+		aliases: []string{"help"},
+		hidden:  true,
+		help:    ":egg:",
+		params:  []Param{{name: "command", kind: ParamRest, optional: true}},
 		callback: func(ca CommandArgs) bool {
 			// show help for a command
-			if ca.args != "" {
-				for _, cmd := range CommandList {
-					if !HasAccess(ca.sess, cmd, ca.msg) {
-						continue
-					}
-					for _, a := range cmd.aliases {
-						if a == ca.args {
-							ShowHelp(ca, cmd)
-							return false
-						}
+			if ca.Has("command") {
+				cmd, near := FindCommandPartial(ca, ca.Str("command"))
+				if cmd == nil {
+					if len(near) > 0 {
+						SendError(ca, ca.T("help.notfound.suggest", "commands", suggestionList(near, ca.guildID())))
+					} else {
+						SendError(ca, ca.T("help.notfound"))
 					}
+					return false
 				}
-				SendError(ca, "command not found")
+				ShowHelp(ca, *cmd)
 				return false
 			}
 
+			gid := ca.guildID()
+			prefix := GuildPrefix(gid)
+
 			var list []string
 			for _, cmd := range CommandList {
 				if !HasAccess(ca.sess, cmd, ca.msg) {
 					continue
 				}
-				if cmd.hidden {
+				if cmd.hidden || !commandEnabled(ca, cmd) {
 					continue
 				}
-				help := formatTokens(cmd.help)
-				firstline := strings.Split(help, "\n")[0]
-				list = append(list, fmt.Sprintf("%s%s - %s", Config.Prefixes[0], cmd.aliases[0], firstline))
+				list = append(list, fmt.Sprintf("%s%s - %s", prefix, cmd.aliases[0], shortHelp(cmd, gid)))
+				list = append(list, subcommandTree(ca, cmd, "  ")...)
 			}
 
 			pfxText := ""
-			if Config.PrefixOptional {
-				pfxText = "command prefixes are optional!\n"
+			if GuildPrefixOptional(gid) {
+				pfxText = ca.T("help.list.optional") + "\n"
 			}
 
-			QuickEmbed(ca, QEmbed{
-				title:   "bot commands",
-				content: fmt.Sprintf("```%s```", strings.Join(list, "\n")),
-				footer:  fmt.Sprintf("\"%shelp command\" for help with individual commands\n%sprefixes: %s", Config.Prefixes[0], pfxText, strings.Join(Config.Prefixes, " ")),
-				colour:  helpColour,
+			SendPaged(ca, PagedEmbed{
+				title: ca.T("help.list.title"),
+				pages: codeBlocks(paginate(list, maxPageLength)),
+				footer: ca.T("help.list.footer", "prefix", prefix, "optional", pfxText,
+					"prefixes", strings.Join(GuildPrefixes(gid), " "), "bot", ca.sess.State().User.Username),
+				colour: helpColour,
 			})
 
 			return false
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		replied bool
+		isError bool
+		want    string
+	}{
+		{"prefix", "!clockstyle spikes", true, false, "clock style set"},
+		{"mention prefix", "<@1000> clockstyle spikes", true, false, "clock style set"},
+		{"uppercase alias", "!CLOCKSTYLE spikes", true, false, "clock style set"},
+		{"no prefix", "clockstyle spikes", false, false, ""},
+		{"bad param", "!clockstyle squares", true, true, "usage: `!clockstyle <style:circle|spikes>`"},
+		{"unknown command", "!clockstyl spikes", true, true, "did you mean `!clockstyle`"},
+		{"chat", "just talking", false, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestBot(t)
+			f.Receive(testTable, testGM, tt.content)
+
+			sent := f.SentTo(testTable)
+			if !tt.replied {
+				if len(sent) > 0 {
+					t.Fatalf("expected no reply, got %q", embedText(sent[0]))
+				}
+				return
+			}
+
+			m := lastSent(t, f, testTable)
+			if isError(m) != tt.isError {
+				t.Errorf("isError = %v, want %v: %q", isError(m), tt.isError, embedText(m))
+			}
+			if !strings.Contains(embedText(m), tt.want) {
+				t.Errorf("reply %q doesn't contain %q", embedText(m), tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleCommandSubcommands(t *testing.T) {
+	f := newTestBot(t)
+
+	f.Receive(testTable, testAdmin, "!prefix add tb!")
+	if m := lastSent(t, f, testTable); isError(m) {
+		t.Fatalf("prefix add failed: %q", embedText(m))
+	}
+
+	// the new prefix works alongside the old one
+	f.Receive(testTable, testGM, "tb!clockstyle circle")
+	if m := lastSent(t, f, testTable); embedText(m) != "clock style set" {
+		t.Errorf("new prefix didn't work: %q", embedText(m))
+	}
+
+	// and the parent command runs without a subcommand
+	f.Receive(testTable, testGM, "!prefix")
+	if m := lastSent(t, f, testTable); !strings.Contains(embedText(m), "`!` `tb!`") {
+		t.Errorf("prefix list = %q", embedText(m))
+	}
+}
+
+// granting botadmin to a role doesn't lock guild admins out of perms
+func TestBotadminRuleKeepsGuildAdmins(t *testing.T) {
+	f := newTestBot(t)
+
+	f.Receive(testTable, testAdmin, "!perms grant botadmin <@&"+testRoleGM+">")
+	if m := lastSent(t, f, testTable); isError(m) {
+		t.Fatalf("grant failed: %q", embedText(m))
+	}
+
+	f.Receive(testTable, testAdmin, "!perms reset botadmin")
+	if m := lastSent(t, f, testTable); isError(m) || !strings.Contains(embedText(m), "reset to default") {
+		t.Errorf("guild admin couldn't reset botadmin: %q", embedText(m))
+	}
+}
+
+// prefix reset only resets the prefixes
+func TestPrefixResetKeepsOptional(t *testing.T) {
+	f := newTestBot(t)
+	f.Receive(testTable, testAdmin, "!prefix add tb!")
+	f.Receive(testTable, testAdmin, "!prefix optional on")
+	f.Receive(testTable, testAdmin, "!prefix reset")
+
+	if got := GuildPrefixes(testGuild); len(got) != 1 || got[0] != "!" {
+		t.Errorf("prefixes = %v", got)
+	}
+	guildConfigMutex.Lock()
+	optional := guildConfigs[testGuild].PrefixOptional
+	guildConfigMutex.Unlock()
+	if optional == nil || !*optional {
+		t.Errorf("prefix reset changed optional to %v", optional)
+	}
+}
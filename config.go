@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// people edit config.json by hand so it has no version envelope
+var configFile = &settingsFile{name: "config.json", plain: true}
+
+type configJSON struct {
+	Token          string
+	OwnerID        string
+	OwnerIDs       []string
+	Admins         []string
+	Prefixes       []string
+	PrefixOptional bool
+	Status         string
+	SendErrors     bool
+	SlashCommands  bool
+	ServerMembers  bool
+	Shards         int
+	Language       string
+	Log            logConfig
+	Control        controlConfig
+	Storage        storageConfig
+}
+
+var configValue atomic.Value
+
+// Config returns the current config.json
+//	it's swapped out whole on reload, so never modify it
+//	and call Config again instead of keeping it around
+func Config() *configJSON {
+	c, _ := configValue.Load().(*configJSON)
+	if c == nil {
+		return &configJSON{}
+	}
+	return c
+}
+
+func setConfig(c *configJSON) {
+	configValue.Store(c)
+}
+
+// loadConfig reads and validates config.json without applying it
+func loadConfig() (*configJSON, error) {
+	c := &configJSON{}
+	err := configFile.load(c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateConfig(c)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config.json: %w", err)
+	}
+	return c, nil
+}
+
+func validateConfig(c *configJSON) error {
+	if c.Token == "" {
+		return errors.New("token is empty")
+	}
+	if len(c.Prefixes) == 0 {
+		return errors.New("prefixes is empty")
+	}
+	for _, p := range c.Prefixes {
+		if p == "" || strings.ContainsAny(p, " \t\n") {
+			return fmt.Errorf("prefix %q can't be empty or contain spaces", p)
+		}
+	}
+	if _, ok := locales[c.Language]; c.Language != "" && !ok {
+		return fmt.Errorf("unknown language %q, use one of %s", c.Language, strings.Join(languageCodes(), ", "))
+	}
+	if c.Shards < 0 {
+		return errors.New("shards can't be negative, use 0 for discord's recommendation")
+	}
+	err := validateControlConfig(c.Control)
+	if err != nil {
+		return err
+	}
+	err = validateStorageConfig(c.Storage)
+	if err != nil {
+		return err
+	}
+	return validateLogConfig(c.Log)
+}
+
+// saveConfig writes a config back to config.json
+func saveConfig(c *configJSON) error {
+	return configFile.save(c)
+}
+
+// held while the config is being changed, so one change can't overwrite another
+//	Config doesn't need it, the config is swapped in whole
+var configMutex sync.Mutex
+
+// updateConfig changes a copy of the config, swaps it in and saves it
+//	the change is saved over what's in config.json rather than the running
+//	config, so edits waiting for a reload or restart aren't lost
+func updateConfig(fn func(*configJSON)) error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	c := *Config()
+	fn(&c)
+	setConfig(&c)
+
+	file := &configJSON{}
+	err := configFile.load(file)
+	if err != nil {
+		return err
+	}
+	fn(file)
+	return saveConfig(file)
+}
+
+// config.json fields that are only read on startup
+var restartFields = []string{"Shards", "ServerMembers", "Control", "Storage"}
+
+// keepRestartFields copies the fields that need a restart from old into c
+//	returns the names of those that were changed in c
+func keepRestartFields(c *configJSON, old *configJSON) []string {
+	var restart []string
+	cv := reflect.ValueOf(c).Elem()
+	ov := reflect.ValueOf(old).Elem()
+	for _, name := range restartFields {
+		if !reflect.DeepEqual(cv.FieldByName(name).Interface(), ov.FieldByName(name).Interface()) {
+			restart = append(restart, strings.ToLower(name))
+			cv.FieldByName(name).Set(ov.FieldByName(name))
+		}
+	}
+	return restart
+}
+
+// configChanges returns the names of fields that differ between two configs
+func configChanges(old *configJSON, new *configJSON) []string {
+	var changed []string
+	ov := reflect.ValueOf(*old)
+	nv := reflect.ValueOf(*new)
+	for i := 0; i < ov.NumField(); i++ {
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, strings.ToLower(ov.Type().Field(i).Name))
+		}
+	}
+	return changed
+}
+
+// reloadConfig reads config.json again and applies anything that changed
+//	prefixes, owners, admins, language and senderrors are read live so only need swapping in,
+//	logging is set up again, status and slash commands are sent to discord,
+//	and a new token reconnects every shard,
+//	the shard count, servermembers, control server and storage need a restart,
+//	so they keep their old values until then
+//	returns the names of changed fields, and of fields waiting for a restart
+func reloadConfig(sessions []*discordgo.Session) ([]string, []string, error) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	c, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	old := Config()
+	restart := keepRestartFields(c, old)
+	changed := configChanges(old, c)
+	if len(changed) == 0 {
+		return nil, restart, nil
+	}
+	setConfig(c)
+
+	if !reflect.DeepEqual(c.Log, old.Log) {
+		err = configureLogging(c.Log)
+		if err != nil {
+			return changed, restart, fmt.Errorf("config reloaded but logging couldn't be set up: %w", err)
+		}
+	}
+
+	if c.Token != old.Token {
+		for i, sess := range sessions {
+			if i > 0 {
+				time.Sleep(identifyInterval)
+			}
+			err = reconnect(sess, c.Token)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			// keep running on the old token
+			for i, sess := range sessions {
+				if i > 0 {
+					time.Sleep(identifyInterval)
+				}
+				reconnect(sess, old.Token)
+			}
+			setConfig(old)
+			return nil, restart, fmt.Errorf("couldn't connect with the new token: %w", err)
+		}
+		// ready sets status and slash commands after reconnecting
+		return changed, restart, nil
+	}
+
+	if c.Status != old.Status {
+		for _, sess := range sessions {
+			sess.UpdateGameStatus(0, c.Status)
+		}
+	}
+	if c.SlashCommands && !old.SlashCommands {
+		registerSlashCommands(sessions[0])
+	}
+	return changed, restart, nil
+}
+
+// closes the gateway and opens it again with a token
+func reconnect(sess *discordgo.Session, token string) error {
+	sess.Close()
+	sess.Token = "Bot " + token
+	sess.Identify.Token = sess.Token
+	return sess.Open()
+}
+
+// result of a reload requested by a command
+type configReload struct {
+	changed []string
+	restart []string
+	err     error
+}
+
+// reloads are done by main, which owns the session
+var configReloads = make(chan chan configReload)
+
+// requestReload asks main to reload config.json and waits for it
+//	gives up if shutdown starts, since main stops taking requests then
+func requestReload() ([]string, []string, error) {
+	// buffered so main doesn't block answering a request that gave up
+	res := make(chan configReload, 1)
+	select {
+	case configReloads <- res:
+	case <-shutdownStarted:
+		return nil, nil, errShuttingDown
+	}
+	select {
+	case r := <-res:
+		return r.changed, r.restart, r.err
+	case <-shutdownStarted:
+		return nil, nil, errShuttingDown
+	}
+}
+
+func init() {
+	RegisterCommand(Command{
+		aliases:  []string{"reload", "reloadconfig"},
+		help:     "reload config.json",
+		emptyArg: true,
+		tier:     TierOwner,
+		callback: func(ca CommandArgs) bool {
+			changed, restart, err := requestReload()
+			if err != nil {
+				SendError(ca, err.Error())
+				return false
+			}
+			content := ca.T("reload.unchanged")
+			if len(changed) > 0 {
+				content = ca.T("reload.changed", "fields", strings.Join(changed, ", "))
+			}
+			if len(restart) > 0 {
+				content += "\n" + ca.T("reload.restart", "fields", strings.Join(restart, ", "))
+			}
+			QuickEmbed(ca, QEmbed{title: ca.T("reload.title"), content: content})
+			return false
+		}})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReloadKeepsRestartFields(t *testing.T) {
+	newTestBot(t)
+	running := &configJSON{Token: "abc", Prefixes: []string{"!"}, Shards: 1}
+	setConfig(running)
+
+	file := *running
+	file.Prefixes = []string{"?"}
+	file.Shards = 4
+	file.ServerMembers = true
+	file.Storage = storageConfig{Backend: "bolt"}
+	if err := saveConfig(&file); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, restart, err := reloadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{"prefixes"}) {
+		t.Errorf("changed = %v", changed)
+	}
+	if !reflect.DeepEqual(restart, []string{"shards", "servermembers", "storage"}) {
+		t.Errorf("restart = %v", restart)
+	}
+	c := Config()
+	if c.Prefixes[0] != "?" || c.Shards != 1 || c.ServerMembers || c.Storage.Backend != "" {
+		t.Errorf("running config = %+v", c)
+	}
+
+	// saving the status doesn't undo edits waiting for a restart
+	if err := updateConfig(func(c *configJSON) { c.Status = "playing" }); err != nil {
+		t.Fatal(err)
+	}
+	saved := &configJSON{}
+	if err := configFile.load(saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != "playing" || saved.Shards != 4 || !saved.ServerMembers {
+		t.Errorf("config.json = %+v", saved)
+	}
+	if Config().Status != "playing" || Config().Shards != 1 {
+		t.Errorf("running config = %+v", Config())
+	}
+}
@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "net"
+
+// listenUnix listens on a unix socket
+//	windows doesn't use unix permissions, the socket gets the ACL of its directory
+func listenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
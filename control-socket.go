@@ -0,0 +1,30 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenUnix listens on a unix socket that only the bot's user can open
+//	the umask is set around Listen so the socket never exists with looser
+//	permissions, files other goroutines create meanwhile are just 0600 too
+func listenUnix(path string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	ln, err := net.Listen("unix", path)
+	syscall.Umask(old)
+	if err != nil {
+		return nil, err
+	}
+
+	// refuse to run with a socket that isn't private
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
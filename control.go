@@ -0,0 +1,292 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var controlLog = NewLogger("control")
+
+// controlConfig is the "control" section of config.json
+//	- listen is a localhost address like "127.0.0.1:8099",
+//		or "unix:" and a socket path, the control server is off if empty
+//	- token is required as "Authorization: Bearer <token>", it can only be left
+//		empty for a unix socket, which only the bot's user can open
+type controlConfig struct {
+	Listen string
+	Token  string
+}
+
+func validateControlConfig(cc controlConfig) error {
+	if cc.Listen == "" || strings.HasPrefix(cc.Listen, "unix:") {
+		return nil
+	}
+	if cc.Token == "" {
+		return errors.New("control server needs a token unless it listens on a unix socket")
+	}
+	host, _, err := net.SplitHostPort(cc.Listen)
+	if err != nil {
+		return fmt.Errorf("control listen address: %w", err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return errors.New("control server can only listen on localhost or a unix socket")
+	}
+	return nil
+}
+
+// controlServer is a local HTTP interface for running the bot when discord is misbehaving
+//	GET  /stats                  runtime stats
+//	POST /status?status=         set status
+//	GET  /guilds                 guilds the bot is in
+//	GET  /shards                 every shard's guilds, connection and latency
+//	GET  /music                  active music sessions and their queues
+//	GET  /clocks?guild=          clocks, for every guild if guild is empty
+//	POST /save                   save guild settings and music queues now
+//	POST /reload                 reload config.json
+//	POST /stop?guild=            stop a guild's playback
+//	GET  /metrics                prometheus metrics, see metrics.go
+type controlServer struct {
+	sess   Transport
+	token  string
+	unix   bool
+	server *http.Server
+	mux    *http.ServeMux
+}
+
+// startControlServer starts listening if config.json has a control address
+//	returns nil if it doesn't
+func startControlServer(sess Transport, cc controlConfig) (*controlServer, error) {
+	if cc.Listen == "" {
+		return nil, nil
+	}
+
+	var ln net.Listener
+	var err error
+	if strings.HasPrefix(cc.Listen, "unix:") {
+		path := strings.TrimPrefix(cc.Listen, "unix:")
+		// left behind if the bot didn't shut down cleanly
+		os.Remove(path)
+		ln, err = listenUnix(path)
+	} else {
+		ln, err = net.Listen("tcp", cc.Listen)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't listen on %s: %w", cc.Listen, err)
+	}
+
+	cs := &controlServer{sess: sess, token: cc.Token, unix: strings.HasPrefix(cc.Listen, "unix:"), mux: http.NewServeMux()}
+	cs.handle("/stats", http.MethodGet, cs.stats)
+	cs.handle("/status", http.MethodPost, cs.status)
+	cs.handle("/guilds", http.MethodGet, cs.guilds)
+	cs.handle("/shards", http.MethodGet, cs.shards)
+	cs.handle("/music", http.MethodGet, cs.music)
+	cs.handle("/clocks", http.MethodGet, cs.clocks)
+	cs.handle("/save", http.MethodPost, cs.save)
+	cs.handle("/reload", http.MethodPost, cs.reload)
+	cs.handle("/stop", http.MethodPost, cs.stop)
+	cs.mux.HandleFunc("/metrics", cs.metrics)
+
+	cs.server = &http.Server{Handler: cs.mux, ReadTimeout: 10 * time.Second, WriteTimeout: 30 * time.Second}
+	go func() {
+		err := cs.server.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			controlLog.Error("control server stopped", "err", err)
+		}
+	}()
+	controlLog.Info("control server listening", "addr", cc.Listen)
+	return cs, nil
+}
+
+// Close stops the control server
+func (cs *controlServer) Close() error {
+	return cs.server.Close()
+}
+
+// handle registers an endpoint, checking its method and the token
+func (cs *controlServer) handle(path string, method string, fn func(*http.Request) (interface{}, error)) {
+	cs.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		defer recoverGoroutine(cs.sess, "control "+path, "", "")
+
+		if !cs.authorized(r) {
+			writeControlJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		if r.Method != method {
+			writeControlJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use " + method})
+			return
+		}
+
+		controlLog.Info("control request", "path", path, "query", r.URL.RawQuery)
+		res, err := fn(r)
+		if err != nil {
+			writeControlJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeControlJSON(w, http.StatusOK, res)
+	})
+}
+
+// prometheus sends the token with bearer_token in its scrape config
+//	over tcp the Host has to be local too, so a web page can't reach the server
+//	by pointing its own domain at 127.0.0.1
+func (cs *controlServer) authorized(r *http.Request) bool {
+	if !cs.unix && !localHost(r.Host) {
+		return false
+	}
+	if cs.token == "" {
+		return cs.unix
+	}
+	want := []byte("Bearer " + cs.token)
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) == 1
+}
+
+// localHost checks that a Host header is localhost or a loopback address
+func localHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	host = strings.Trim(host, "[]")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeControlJSON(w http.ResponseWriter, code int, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		code = http.StatusInternalServerError
+		b = []byte(`{"error": "couldn't marshal response"}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(append(b, '\n'))
+}
+
+// metrics is plain text so it doesn't go through handle
+func (cs *controlServer) metrics(w http.ResponseWriter, r *http.Request) {
+	defer recoverGoroutine(cs.sess, "control /metrics", "", "")
+
+	if !cs.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetrics(w, cs.sess)
+}
+
+func (cs *controlServer) stats(r *http.Request) (interface{}, error) {
+	return getRuntimeStats(cs.sess), nil
+}
+
+func (cs *controlServer) status(r *http.Request) (interface{}, error) {
+	status := r.FormValue("status")
+	err := setStatus(cs.sess, status)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"status": status}, nil
+}
+
+type controlGuild struct {
+	ID           string
+	Name         string
+	Members      int
+	Shard        int
+	MusicChannel string `json:",omitempty"`
+	Playing      bool
+}
+
+func (cs *controlServer) guilds(r *http.Request) (interface{}, error) {
+	var guilds []controlGuild
+	eachShard(cs.sess, func(t Transport) {
+		state := t.State()
+		state.RLock()
+		for _, g := range state.Guilds {
+			guilds = append(guilds, controlGuild{ID: g.ID, Name: g.Name, Members: g.MemberCount, Shard: shardID(t)})
+		}
+		state.RUnlock()
+	})
+
+	for i, g := range guilds {
+		guilds[i].MusicChannel, _ = guildMusicChannel(g.ID)
+		if ms := findGuildSession(g.ID); ms != nil {
+			guilds[i].Playing = ms.info().Playing
+		}
+	}
+	return guilds, nil
+}
+
+func (cs *controlServer) shards(r *http.Request) (interface{}, error) {
+	return shardStatuses(), nil
+}
+
+func (cs *controlServer) music(r *http.Request) (interface{}, error) {
+	var out []musicSessionInfo
+	for _, ms := range musicSessions() {
+		out = append(out, ms.info())
+	}
+	return out, nil
+}
+
+func (cs *controlServer) clocks(r *http.Request) (interface{}, error) {
+	gid := r.FormValue("guild")
+
+	if gid != "" {
+		return map[string]guildClockSettings{gid: clockSettings(gid)}, nil
+	}
+
+	gids, err := clockStorage.IDs()
+	if err != nil {
+		return nil, err
+	}
+	clocks := make(map[string]guildClockSettings)
+	for _, gid := range gids {
+		clocks[gid] = clockSettings(gid)
+	}
+	return clocks, nil
+}
+
+// music, clocks and everything else on Storage is written as it changes,
+// so only guild settings and music queues need saving
+func (cs *controlServer) save(r *http.Request) (interface{}, error) {
+	err := saveAllSettings()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't save %s: %w", guildsFile.name, err)
+	}
+	err = saveQueues()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't save %s: %w", queuesFile.name, err)
+	}
+	return map[string]string{"saved": guildsFile.name + ", " + queuesFile.name}, nil
+}
+
+func (cs *controlServer) reload(r *http.Request) (interface{}, error) {
+	changed, restart, err := requestReload()
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]string{"changed": changed, "restart": restart}, nil
+}
+
+func (cs *controlServer) stop(r *http.Request) (interface{}, error) {
+	gid := r.FormValue("guild")
+	ms := findGuildSession(gid)
+	if ms == nil {
+		return nil, errors.New("no music session in that guild")
+	}
+	ms.Stop()
+	return map[string]string{"stopped": gid}, nil
+}
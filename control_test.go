@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestControlAuthorized(t *testing.T) {
+	tests := []struct {
+		name  string
+		unix  bool
+		token string
+		host  string
+		auth  string
+		want  bool
+	}{
+		{name: "token", token: "secret", host: "127.0.0.1:8099", auth: "Bearer secret", want: true},
+		{name: "localhost", token: "secret", host: "localhost:8099", auth: "Bearer secret", want: true},
+		{name: "ipv6", token: "secret", host: "[::1]:8099", auth: "Bearer secret", want: true},
+		{name: "wrong token", token: "secret", host: "127.0.0.1:8099", auth: "Bearer nope"},
+		{name: "no token sent", token: "secret", host: "127.0.0.1:8099"},
+		{name: "rebound host", token: "secret", host: "evil.example:8099", auth: "Bearer secret"},
+		{name: "tcp without token", host: "127.0.0.1:8099"},
+		{name: "unix without token", unix: true, host: "localhost", want: true},
+		{name: "unix with token", unix: true, token: "secret", host: "anything", auth: "Bearer secret", want: true},
+		{name: "unix wrong token", unix: true, token: "secret", host: "anything", auth: "Bearer nope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := &controlServer{unix: tt.unix, token: tt.token}
+			r := httptest.NewRequest("GET", "/stats", nil)
+			r.Host = tt.host
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			if got := cs.authorized(r); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateControlConfig(t *testing.T) {
+	tests := []struct {
+		cc controlConfig
+		ok bool
+	}{
+		{controlConfig{}, true},
+		{controlConfig{Listen: "unix:/tmp/tussbot.sock"}, true},
+		{controlConfig{Listen: "127.0.0.1:8099", Token: "secret"}, true},
+		{controlConfig{Listen: "127.0.0.1:8099"}, false},
+		{controlConfig{Listen: "0.0.0.0:8099", Token: "secret"}, false},
+	}
+	for _, tt := range tests {
+		if err := validateControlConfig(tt.cc); (err == nil) != tt.ok {
+			t.Errorf("%+v: got %v", tt.cc, err)
+		}
+	}
+}
+
+func TestControlSocket(t *testing.T) {
+	f := newTestBot(t)
+	path := filepath.Join(t.TempDir(), "control.sock")
+	cs, err := startControlServer(f, controlConfig{Listen: "unix:" + path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != 0600 {
+			t.Errorf("socket mode = %v, want 0600", mode)
+		}
+	}
+
+	client := &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return net.Dial("unix", path)
+	}}}
+	res, err := client.Post("http://localhost/save", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body map[string]string
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["saved"] != "guilds.json, queues.json" {
+		t.Errorf("saved = %q", body["saved"])
+	}
+	for _, name := range []string{"guilds.json", "queues.json"} {
+		if _, err := os.Stat(filepath.Join(settingsDir, name)); err != nil {
+			t.Errorf("%s wasn't written: %v", name, err)
+		}
+	}
+}
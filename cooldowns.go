@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// CooldownScope is what a cooldown's uses are counted per
+type CooldownScope int
+
+// cooldown scopes
+const (
+	CooldownUser CooldownScope = iota
+	CooldownChannel
+	CooldownGuild
+)
+
+// Cooldown limits how often a command can be used
+//	burst uses are allowed within any window, after that
+//	the oldest use has to expire before the command works again
+type Cooldown struct {
+	scope  CooldownScope
+	burst  int
+	window time.Duration
+}
+
+// how long "slow down" messages stay up
+var cooldownMessageTimeout = 5
+
+var cooldownMutex sync.Mutex
+var cooldownUses = make(map[string][]time.Time)
+var cooldownWarned = make(map[string]time.Time)
+var cooldownSwept = time.Now()
+
+func cooldownKey(ca CommandArgs, cmd *Command, cd Cooldown) string {
+	id := ""
+	switch cd.scope {
+	case CooldownUser:
+		id = "u" + ca.msg.Author.ID
+	case CooldownChannel:
+		id = "c" + ca.msg.ChannelID
+	case CooldownGuild:
+		id = "g" + ca.msg.GuildID
+	}
+	return fmt.Sprintf("%s/%s/%d", cmd.path, id, cd.window)
+}
+
+// drops uses older than window
+func pruneUses(uses []time.Time, window time.Duration, now time.Time) []time.Time {
+	i := 0
+	for i < len(uses) && now.Sub(uses[i]) >= window {
+		i++
+	}
+	return uses[i:]
+}
+
+// removes keys that haven't been used in a while so the maps don't grow forever
+//	must be called with cooldownMutex locked
+func sweepCooldowns(now time.Time) {
+	if now.Sub(cooldownSwept) < 10*time.Minute {
+		return
+	}
+	cooldownSwept = now
+
+	for key, uses := range cooldownUses {
+		if len(uses) == 0 || now.Sub(uses[len(uses)-1]) > time.Hour {
+			delete(cooldownUses, key)
+		}
+	}
+	for key, until := range cooldownWarned {
+		if now.After(until) {
+			delete(cooldownWarned, key)
+		}
+	}
+}
+
+// useCooldowns records a use of cmd if none of its cooldowns are exceeded
+//	returns how long until the command can be used if one is,
+//	and whether the caller was already told to slow down
+func useCooldowns(ca CommandArgs, cmd *Command) (time.Duration, bool) {
+	if len(cmd.cooldowns) == 0 || ca.msg == nil {
+		return 0, false
+	}
+
+	cooldownMutex.Lock()
+	defer cooldownMutex.Unlock()
+
+	now := time.Now()
+	sweepCooldowns(now)
+
+	var wait time.Duration
+	waitKey := ""
+	for _, cd := range cmd.cooldowns {
+		key := cooldownKey(ca, cmd, cd)
+		uses := pruneUses(cooldownUses[key], cd.window, now)
+		cooldownUses[key] = uses
+
+		if len(uses) >= cd.burst {
+			w := cd.window - now.Sub(uses[0])
+			if w > wait {
+				wait = w
+				waitKey = key
+			}
+		}
+	}
+
+	if wait > 0 {
+		// only warn once per cooldown so the warnings aren't spam themselves
+		warned := now.Before(cooldownWarned[waitKey])
+		cooldownWarned[waitKey] = now.Add(wait)
+		return wait, warned
+	}
+
+	for _, cd := range cmd.cooldowns {
+		key := cooldownKey(ca, cmd, cd)
+		cooldownUses[key] = append(cooldownUses[key], now)
+	}
+	return 0, false
+}
+
+// checkCooldowns returns false and tells the user to slow down if cmd is on cooldown
+func checkCooldowns(ca CommandArgs, cmd *Command) bool {
+	wait, warned := useCooldowns(ca, cmd)
+	if wait == 0 {
+		return true
+	}
+	if !warned {
+		secs := int(math.Ceil(wait.Seconds()))
+		SendErrorTemp(ca, ca.TN("cooldown.wait", secs), ClampI(secs, 1, cooldownMessageTimeout))
+	}
+	return false
+}
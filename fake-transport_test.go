@@ -0,0 +1,481 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// FakeTransport is an in-memory Transport for running commands without a gateway
+//	- guilds, channels and members are added to its state with the Add functions
+//	- Receive sends a message through HandleCommand as if a user typed it
+//	- everything the bot sends is kept in order in Sent, deletes in Deleted
+//	- React delivers a reaction to any ButtonizedMessage listening for one
+//	- voice connections accept opus frames and count them in Frames
+//	- members added with AddOfflineMember are only in state after RequestMembers,
+//		like members of a large guild, and RequestMembers fails with MembersErr if it's set
+type FakeTransport struct {
+	sync.Mutex
+	state    *discordgo.State
+	messages map[string]*discordgo.Message
+	offline  map[string][]*discordgo.Member
+	users    map[string]*discordgo.User
+	watching map[string][]*fakeReactions
+	voice    map[string]chan bool
+	lastID   int
+
+	Sent      []*discordgo.Message
+	Deleted   []string
+	Reactions map[string][]string
+	Files     map[string][]byte
+	Status    string
+	Frames    int
+
+	MembersErr     error
+	MemberRequests int
+}
+
+// NewFakeTransport returns an empty FakeTransport logged in as the given bot user
+func NewFakeTransport(botID string, botName string) *FakeTransport {
+	f := &FakeTransport{
+		state:     discordgo.NewState(),
+		messages:  make(map[string]*discordgo.Message),
+		watching:  make(map[string][]*fakeReactions),
+		offline:   make(map[string][]*discordgo.Member),
+		users:     make(map[string]*discordgo.User),
+		voice:     make(map[string]chan bool),
+		Reactions: make(map[string][]string),
+		Files:     make(map[string][]byte),
+	}
+	f.state.User = &discordgo.User{ID: botID, Username: botName, Bot: true}
+	f.users[botID] = f.state.User
+	return f
+}
+
+// fake snowflakes, unique per transport
+//	must be called with f locked
+func (f *FakeTransport) newID() string {
+	f.lastID++
+	return fmt.Sprintf("%d", 100000000000000000+f.lastID)
+}
+
+// AddGuild adds a guild owned by ownerID
+func (f *FakeTransport) AddGuild(gid string, name string, ownerID string) *discordgo.Guild {
+	g := &discordgo.Guild{ID: gid, Name: name, OwnerID: ownerID}
+	f.state.GuildAdd(g)
+	// @everyone role
+	f.state.RoleAdd(gid, &discordgo.Role{ID: gid, Name: "@everyone", Permissions: discordgo.PermissionViewChannel | discordgo.PermissionSendMessages})
+	return g
+}
+
+// AddRole adds a role to a guild
+func (f *FakeTransport) AddRole(gid string, rid string, name string, perms int64) *discordgo.Role {
+	r := &discordgo.Role{ID: rid, Name: name, Permissions: perms}
+	f.state.RoleAdd(gid, r)
+	return r
+}
+
+// AddChannel adds a text or voice channel to a guild
+func (f *FakeTransport) AddChannel(gid string, chid string, name string, kind discordgo.ChannelType) *discordgo.Channel {
+	ch := &discordgo.Channel{ID: chid, GuildID: gid, Name: name, Type: kind, Bitrate: 64000}
+	f.state.ChannelAdd(ch)
+	return ch
+}
+
+// AddMember adds a user to a guild with some roles
+func (f *FakeTransport) AddMember(gid string, user *discordgo.User, roles ...string) *discordgo.Member {
+	f.Lock()
+	f.users[user.ID] = user
+	f.Unlock()
+
+	m := &discordgo.Member{GuildID: gid, User: user, Roles: roles}
+	f.state.MemberAdd(m)
+	f.countMember(gid)
+	return m
+}
+
+// AddOfflineMember adds a member that isn't in state until it's requested
+func (f *FakeTransport) AddOfflineMember(gid string, user *discordgo.User, roles ...string) *discordgo.Member {
+	f.Lock()
+	f.users[user.ID] = user
+	m := &discordgo.Member{GuildID: gid, User: user, Roles: roles}
+	f.offline[gid] = append(f.offline[gid], m)
+	f.Unlock()
+
+	f.countMember(gid)
+	return m
+}
+
+// bumps the guild's member count like GUILD_CREATE would have it
+func (f *FakeTransport) countMember(gid string) {
+	g, err := f.state.Guild(gid)
+	if err != nil {
+		return
+	}
+	f.state.Lock()
+	g.MemberCount++
+	f.state.Unlock()
+}
+
+// SetVoiceState puts a member in a voice channel, or takes them out if chid is empty
+func (f *FakeTransport) SetVoiceState(gid string, chid string, uid string) error {
+	g, err := f.state.Guild(gid)
+	if err != nil {
+		return err
+	}
+
+	f.state.Lock()
+	defer f.state.Unlock()
+	for i, vs := range g.VoiceStates {
+		if vs.UserID == uid {
+			g.VoiceStates = append(g.VoiceStates[:i], g.VoiceStates[i+1:]...)
+			break
+		}
+	}
+	if chid != "" {
+		g.VoiceStates = append(g.VoiceStates, &discordgo.VoiceState{GuildID: gid, ChannelID: chid, UserID: uid})
+	}
+	return nil
+}
+
+// Receive sends a message from a user through HandleCommand and returns it
+//	the message is sent by a guild member if the channel is in a guild
+func (f *FakeTransport) Receive(chid string, author *discordgo.User, content string) *discordgo.Message {
+	f.Lock()
+	m := &discordgo.Message{ID: f.newID(), ChannelID: chid, Content: content, Author: author}
+	f.messages[m.ID] = m
+	f.Unlock()
+
+	if ch, err := f.state.Channel(chid); err == nil && ch.GuildID != "" {
+		m.GuildID = ch.GuildID
+		if mem, err := f.state.Member(ch.GuildID, author.ID); err == nil {
+			// copy like discordgo does, HandleCommand fills in missing fields
+			m.Member = &discordgo.Member{Roles: mem.Roles}
+		}
+	}
+
+	HandleCommand(f, m)
+	return m
+}
+
+// React adds a user's reaction to a message and delivers it to anything listening to the message
+func (f *FakeTransport) React(msg *discordgo.Message, uid string, emoji string) {
+	f.Lock()
+	watching := append([]*fakeReactions{}, f.watching[msg.ID]...)
+	f.Unlock()
+
+	ev := &discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{
+		UserID:    uid,
+		MessageID: msg.ID,
+		ChannelID: msg.ChannelID,
+		GuildID:   msg.GuildID,
+		Emoji:     discordgo.Emoji{Name: emoji},
+	}}
+	for _, w := range watching {
+		select {
+		case w.ch <- ev:
+		case <-w.done:
+		}
+	}
+}
+
+// SentTo returns the messages sent to a channel that haven't been deleted
+func (f *FakeTransport) SentTo(chid string) []*discordgo.Message {
+	f.Lock()
+	defer f.Unlock()
+
+	var out []*discordgo.Message
+	for _, m := range f.Sent {
+		if m.ChannelID == chid && f.messages[m.ID] != nil {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// State returns the in-memory state
+func (f *FakeTransport) State() *discordgo.State {
+	return f.state
+}
+
+// Channel returns a channel from state
+func (f *FakeTransport) Channel(chid string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return f.state.Channel(chid)
+}
+
+// User returns a user added with AddMember
+func (f *FakeTransport) User(uid string, options ...discordgo.RequestOption) (*discordgo.User, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	u, ok := f.users[uid]
+	if !ok {
+		return nil, errors.New("unknown user")
+	}
+	return u, nil
+}
+
+// UserChannelCreate returns a DM channel with a user
+func (f *FakeTransport) UserChannelCreate(uid string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	if _, err := f.User(uid); err != nil {
+		return nil, err
+	}
+
+	chid := "dm" + uid
+	if ch, err := f.state.Channel(chid); err == nil {
+		return ch, nil
+	}
+	ch := &discordgo.Channel{ID: chid, Type: discordgo.ChannelTypeDM}
+	f.state.ChannelAdd(ch)
+	return ch, nil
+}
+
+// Guild returns a guild from state
+func (f *FakeTransport) Guild(gid string, options ...discordgo.RequestOption) (*discordgo.Guild, error) {
+	return f.state.Guild(gid)
+}
+
+// GuildRoles returns a guild's roles from state
+func (f *FakeTransport) GuildRoles(gid string, options ...discordgo.RequestOption) ([]*discordgo.Role, error) {
+	g, err := f.state.Guild(gid)
+	if err != nil {
+		return nil, err
+	}
+	return g.Roles, nil
+}
+
+// GuildMember returns a member from state or one added with AddOfflineMember
+func (f *FakeTransport) GuildMember(gid string, uid string, options ...discordgo.RequestOption) (*discordgo.Member, error) {
+	if m, err := f.state.Member(gid, uid); err == nil {
+		return m, nil
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	for _, m := range f.offline[gid] {
+		if m.User.ID == uid {
+			return m, nil
+		}
+	}
+	return nil, errors.New("unknown member")
+}
+
+// RequestMembers moves members added with AddOfflineMember into state
+func (f *FakeTransport) RequestMembers(gid string, timeout time.Duration) error {
+	f.Lock()
+	f.MemberRequests++
+	if f.MembersErr != nil {
+		f.Unlock()
+		return f.MembersErr
+	}
+	members := f.offline[gid]
+	delete(f.offline, gid)
+	f.Unlock()
+
+	for _, m := range members {
+		err := f.state.MemberAdd(m)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChannelMessage returns a message that hasn't been deleted
+func (f *FakeTransport) ChannelMessage(chid string, mid string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	m, ok := f.messages[mid]
+	if !ok || m.ChannelID != chid {
+		return nil, errors.New("unknown message")
+	}
+	return m, nil
+}
+
+// records a message sent by the bot
+func (f *FakeTransport) send(chid string, m *discordgo.Message) (*discordgo.Message, error) {
+	if _, err := f.state.Channel(chid); err != nil {
+		return nil, fmt.Errorf("unknown channel: %w", err)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	m.ID = f.newID()
+	m.ChannelID = chid
+	m.Author = f.state.User
+	if ch, err := f.state.Channel(chid); err == nil {
+		m.GuildID = ch.GuildID
+	}
+	f.messages[m.ID] = m
+	f.Sent = append(f.Sent, m)
+	return m, nil
+}
+
+// ChannelMessageSend records a text message
+func (f *FakeTransport) ChannelMessageSend(chid string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return f.send(chid, &discordgo.Message{Content: content})
+}
+
+// ChannelMessageSendEmbed records an embed
+func (f *FakeTransport) ChannelMessageSendEmbed(chid string, em *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return f.send(chid, &discordgo.Message{Embeds: []*discordgo.MessageEmbed{em}})
+}
+
+// ChannelFileSend records a file, its contents are kept in Files by message ID
+func (f *FakeTransport) ChannelFileSend(chid string, name string, r io.Reader, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := f.send(chid, &discordgo.Message{Attachments: []*discordgo.MessageAttachment{{Filename: name, Size: len(data)}}})
+	if err != nil {
+		return nil, err
+	}
+	f.Lock()
+	f.Files[m.ID] = data
+	f.Unlock()
+	return m, nil
+}
+
+// ChannelMessageEditComplex edits a message's content and embeds
+func (f *FakeTransport) ChannelMessageEditComplex(me *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	m, ok := f.messages[me.ID]
+	if !ok || m.ChannelID != me.Channel {
+		return nil, errors.New("unknown message")
+	}
+	if me.Content != nil {
+		m.Content = *me.Content
+	}
+	if me.Embed != nil {
+		m.Embeds = []*discordgo.MessageEmbed{me.Embed}
+	}
+	if me.Embeds != nil {
+		m.Embeds = me.Embeds
+	}
+	return m, nil
+}
+
+// ChannelMessageDelete deletes a message
+func (f *FakeTransport) ChannelMessageDelete(chid string, mid string, options ...discordgo.RequestOption) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if _, ok := f.messages[mid]; !ok {
+		return errors.New("unknown message")
+	}
+	delete(f.messages, mid)
+	f.Deleted = append(f.Deleted, mid)
+	return nil
+}
+
+// MessageReactionAdd records a reaction by the bot
+func (f *FakeTransport) MessageReactionAdd(chid string, mid string, emoji string, options ...discordgo.RequestOption) error {
+	f.Lock()
+	defer f.Unlock()
+
+	f.Reactions[mid] = append(removeString(f.Reactions[mid], emoji), emoji)
+	return nil
+}
+
+// MessageReactionRemove does nothing, only the bot's reactions are recorded
+func (f *FakeTransport) MessageReactionRemove(chid string, mid string, emoji string, uid string, options ...discordgo.RequestOption) error {
+	return nil
+}
+
+// MessageReactionsRemoveAll removes every reaction from a message
+func (f *FakeTransport) MessageReactionsRemoveAll(chid string, mid string, options ...discordgo.RequestOption) error {
+	f.Lock()
+	defer f.Unlock()
+
+	delete(f.Reactions, mid)
+	return nil
+}
+
+// a MessageReactions listener
+type fakeReactions struct {
+	ch   chan *discordgo.MessageReactionAdd
+	done chan bool
+}
+
+// MessageReactions returns a channel that gets reactions sent to a message with React
+func (f *FakeTransport) MessageReactions(mid string) (<-chan *discordgo.MessageReactionAdd, func()) {
+	f.Lock()
+	defer f.Unlock()
+
+	w := &fakeReactions{ch: make(chan *discordgo.MessageReactionAdd, 10), done: make(chan bool)}
+	f.watching[mid] = append(f.watching[mid], w)
+	return w.ch, func() {
+		f.Lock()
+		defer f.Unlock()
+
+		var rest []*fakeReactions
+		for _, o := range f.watching[mid] {
+			if o != w {
+				rest = append(rest, o)
+			}
+		}
+		f.watching[mid] = rest
+		close(w.done)
+	}
+}
+
+// ChannelVoiceJoin returns a voice connection that counts opus frames sent to it
+func (f *FakeTransport) ChannelVoiceJoin(gid string, chid string, mute bool, deaf bool) (*discordgo.VoiceConnection, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if stop, ok := f.voice[gid]; ok {
+		close(stop)
+	}
+	stop := make(chan bool)
+	f.voice[gid] = stop
+
+	vc := &discordgo.VoiceConnection{Ready: true, UserID: f.state.User.ID, GuildID: gid, ChannelID: chid, OpusSend: make(chan []byte, 2)}
+	go func() {
+		for {
+			select {
+			case <-vc.OpusSend:
+				f.Lock()
+				f.Frames++
+				f.Unlock()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return vc, nil
+}
+
+// ChannelVoiceLeave stops a voice connection from accepting frames
+func (f *FakeTransport) ChannelVoiceLeave(vc *discordgo.VoiceConnection) error {
+	f.Lock()
+	defer f.Unlock()
+
+	vc.Lock()
+	vc.Ready = false
+	vc.Unlock()
+	if stop, ok := f.voice[vc.GuildID]; ok {
+		close(stop)
+		delete(f.voice, vc.GuildID)
+	}
+	return nil
+}
+
+// UpdateGameStatus records the bot's status
+func (f *FakeTransport) UpdateGameStatus(idle int, name string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	f.Status = name
+	return nil
+}
@@ -31,6 +31,41 @@ type FFMPEGSession struct {
 	paused      bool
 	framesSent  int
 	voiceCh     *discordgo.VoiceConnection
+	log         Logger
+
+	// for panic reports
+	sess  Transport
+	guild string
+}
+
+// every running ffmpeg process, so none are left behind on shutdown
+var ffmpegMutex sync.Mutex
+var ffmpegProcs = make(map[*os.Process]bool)
+
+func trackFFMPEG(p *os.Process, running bool) {
+	ffmpegMutex.Lock()
+	defer ffmpegMutex.Unlock()
+	if running {
+		ffmpegProcs[p] = true
+	} else {
+		delete(ffmpegProcs, p)
+	}
+}
+
+func countFFMPEG() int {
+	ffmpegMutex.Lock()
+	defer ffmpegMutex.Unlock()
+	return len(ffmpegProcs)
+}
+
+// killAllFFMPEG kills any ffmpeg processes still running and returns how many there were
+func killAllFFMPEG() int {
+	ffmpegMutex.Lock()
+	defer ffmpegMutex.Unlock()
+	for p := range ffmpegProcs {
+		p.Kill()
+	}
+	return len(ffmpegProcs)
 }
 
 var frameDuration = 20 // 20, 40, or 60 ms
@@ -40,6 +75,8 @@ var ffmpegBinary = "ffmpeg"
 // Start an ffmpeg session and begin streaming
 //	`done` channel signals io.EOF for natural end of stream as well as legitimate errors
 func (s *FFMPEGSession) Start(url string, seek int, volume float64, vc *discordgo.VoiceConnection, bitrate int, done chan error) {
+	defer recoverGoroutine(s.sess, "ffmpeg Start", s.guild, "")
+
 	s.done = done
 	s.voiceCh = vc
 
@@ -144,6 +181,7 @@ func (s *FFMPEGSession) Start(url string, seek int, volume float64, vc *discordg
 
 	s.ffmpeg = cmd.Process
 	s.Unlock()
+	trackFFMPEG(cmd.Process, true)
 
 	var wg sync.WaitGroup
 	wg.Add(2)
@@ -157,6 +195,7 @@ func (s *FFMPEGSession) Start(url string, seek int, volume float64, vc *discordg
 	wg.Wait()
 
 	err = cmd.Wait()
+	trackFFMPEG(cmd.Process, false)
 	if err != nil {
 		if err.Error() != "signal: killed" {
 			done <- fmt.Errorf("ffmpeg error: %w", err)
@@ -170,6 +209,7 @@ func (s *FFMPEGSession) Start(url string, seek int, volume float64, vc *discordg
 
 func (s *FFMPEGSession) readStderr(stderr io.ReadCloser, wg *sync.WaitGroup) {
 	defer wg.Done()
+	defer recoverGoroutine(s.sess, "ffmpeg readStderr", s.guild, "")
 
 	bufReader := bufio.NewReader(stderr)
 	var outBuf bytes.Buffer
@@ -184,7 +224,7 @@ func (s *FFMPEGSession) readStderr(stderr io.ReadCloser, wg *sync.WaitGroup) {
 
 		if r == '\n' {
 			// TO DO: save to string, send error to owner on encoding completion
-			fmt.Println("[ffmpeg] ", outBuf.String())
+			s.log.Debug(outBuf.String())
 			outBuf.Reset()
 		} else {
 			outBuf.WriteRune(r)
@@ -195,6 +235,7 @@ func (s *FFMPEGSession) readStderr(stderr io.ReadCloser, wg *sync.WaitGroup) {
 
 func (s *FFMPEGSession) readStdout(stdout io.ReadCloser, wg *sync.WaitGroup) {
 	defer wg.Done()
+	defer recoverGoroutine(s.sess, "ffmpeg readStdout", s.guild, "")
 
 	decoder := ogg.NewPacketDecoder(ogg.NewDecoder(stdout))
 
@@ -248,6 +289,8 @@ func (s *FFMPEGSession) GetFrame() (frame []byte, err error) {
 
 // StartStream to discordgo voice connection
 func (s *FFMPEGSession) StartStream() {
+	defer recoverGoroutine(s.sess, "ffmpeg StartStream", s.guild, "")
+
 	s.Lock()
 
 	if s.streaming || s.paused {
@@ -279,6 +322,7 @@ func (s *FFMPEGSession) StartStream() {
 
 		select {
 		case <-loss:
+			voiceSendTimeouts.Inc("")
 			numTimeouts++
 			if numTimeouts >= maxTimeouts {
 				s.Lock()
@@ -303,6 +347,16 @@ func (s *FFMPEGSession) StartStream() {
 	s.Unlock()
 }
 
+// bufferFill returns how many frames are buffered and the buffer's size
+func (s *FFMPEGSession) bufferFill() (int, int) {
+	s.Lock()
+	defer s.Unlock()
+	if !s.encoding {
+		return 0, 0
+	}
+	return len(s.frameBuffer), cap(s.frameBuffer)
+}
+
 // CurrentTime returns current playback position
 func (s *FFMPEGSession) CurrentTime() time.Duration {
 	s.Lock()
@@ -380,6 +434,6 @@ func (s *FFMPEGSession) Stop() {
 func init() {
 	if _, err := os.Stat("./ffmpeg"); err == nil {
 		ffmpegBinary = "./ffmpeg"
-		fmt.Println("local ffmpeg found, using ./ffmpeg")
+		NewLogger("ffmpeg").Info("local ffmpeg found, using ./ffmpeg")
 	}
 }
@@ -4,8 +4,10 @@ go 1.14
 
 require (
 	github.com/DougTy/ogg v0.0.0-20200609100649-ca1630508cc2
-	github.com/bwmarrin/discordgo v0.20.3
+	github.com/bwmarrin/discordgo v0.27.1
 	github.com/fogleman/gg v1.3.0
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
+	go.etcd.io/bbolt v1.3.5
 	golang.org/x/image v0.0.0-20200430140353-33d19683fad8 // indirect
+	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68
 )
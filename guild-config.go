@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// per-guild overrides of config.json
+//	nil/empty fields fall back to the global config
+type guildConfig struct {
+	Prefixes       []string             `json:",omitempty"`
+	PrefixOptional *bool                `json:",omitempty"`
+	Language       string               `json:",omitempty"`
+	Permissions    map[string]*permRule `json:",omitempty"`
+
+	// module name to enabled, per guild and per channel ID
+	Modules        map[string]bool            `json:",omitempty"`
+	ChannelModules map[string]map[string]bool `json:",omitempty"`
+}
+
+var settingsLog = NewLogger("settings")
+
+var guildConfigMutex sync.Mutex
+var guildConfigs = make(map[string]*guildConfig)
+
+var guildsFile = &settingsFile{name: "guilds.json", version: 1}
+
+func loadGuildConfigs() {
+	guildConfigMutex.Lock()
+	defer guildConfigMutex.Unlock()
+
+	err := guildsFile.load(&guildConfigs)
+	if err != nil {
+		logLoadError(guildsFile.name, err)
+	}
+}
+
+// must be called with guildConfigMutex locked
+func saveGuildConfigs() error {
+	err := guildsFile.save(guildConfigs)
+	if err != nil {
+		settingsLog.Error("couldn't save guild configs", "err", err)
+	}
+	return err
+}
+
+// updateGuildConfig modifies a guild's config and saves it
+func updateGuildConfig(gid string, fn func(*guildConfig)) {
+	guildConfigMutex.Lock()
+	defer guildConfigMutex.Unlock()
+
+	gc, ok := guildConfigs[gid]
+	if !ok {
+		gc = &guildConfig{}
+		guildConfigs[gid] = gc
+	}
+	fn(gc)
+	saveGuildConfigs()
+}
+
+// GuildPrefixes returns the prefixes for a guild, or the global ones
+func GuildPrefixes(gid string) []string {
+	guildConfigMutex.Lock()
+	defer guildConfigMutex.Unlock()
+
+	gc, ok := guildConfigs[gid]
+	if ok && len(gc.Prefixes) > 0 {
+		return append([]string{}, gc.Prefixes...)
+	}
+	return append([]string{}, Config().Prefixes...)
+}
+
+// GuildPrefix returns the main prefix for a guild, used in help text
+func GuildPrefix(gid string) string {
+	return GuildPrefixes(gid)[0]
+}
+
+// GuildPrefixOptional returns whether commands need a prefix in a guild
+func GuildPrefixOptional(gid string) bool {
+	guildConfigMutex.Lock()
+	defer guildConfigMutex.Unlock()
+
+	gc, ok := guildConfigs[gid]
+	if ok && gc.PrefixOptional != nil {
+		return *gc.PrefixOptional
+	}
+	return Config().PrefixOptional
+}
+
+// sorts prefixes longest first so "tb!" is checked before "t"
+func sortPrefixes(prefixes []string) []string {
+	sort.SliceStable(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+	return prefixes
+}
+
+func init() {
+	RegisterCommand(Command{
+		aliases: []string{"prefix", "prefixes"},
+		help: `show or change this server's command prefixes\n
+		prefixes can be more than one character, ie ^tb!^
+		mentioning the bot always works as a prefix`,
+		emptyArg: true,
+		noDM:     true,
+		callback: func(ca CommandArgs) bool {
+			optional := ca.T("prefix.required")
+			if GuildPrefixOptional(ca.msg.GuildID) {
+				optional = ca.T("prefix.optional")
+			}
+			QuickEmbed(ca, QEmbed{title: ca.T("prefix.title"), content: fmt.Sprintf("`%s`\n%s",
+				strings.Join(GuildPrefixes(ca.msg.GuildID), "` `"), optional)})
+			return false
+		},
+		subcommands: []Command{
+			{
+				aliases: []string{"add"},
+				help: `add a command prefix\n
+				^%Pprefix add tb!^`,
+				params: []Param{{name: "prefix", pattern: `^\S{1,10}$`, help: "up to 10 characters, no spaces"}},
+				roles:  []string{"botadmin"},
+				callback: func(ca CommandArgs) bool {
+					prefix := strings.ToLower(ca.Str("prefix"))
+					prefixes := GuildPrefixes(ca.msg.GuildID)
+					for _, p := range prefixes {
+						if p == prefix {
+							SendError(ca, ca.T("prefix.exists"))
+							return false
+						}
+					}
+
+					updateGuildConfig(ca.msg.GuildID, func(gc *guildConfig) {
+						gc.Prefixes = append(prefixes, prefix)
+					})
+					QuickEmbed(ca, QEmbed{content: ca.T("prefix.added", "prefix", prefix)})
+					return false
+				}},
+			{
+				aliases: []string{"remove", "rm"},
+				help: `remove a command prefix\n
+				^%Pprefix remove !^`,
+				params: []Param{{name: "prefix"}},
+				roles:  []string{"botadmin"},
+				callback: func(ca CommandArgs) bool {
+					prefix := strings.ToLower(ca.Str("prefix"))
+					prefixes := GuildPrefixes(ca.msg.GuildID)
+
+					var kept []string
+					for _, p := range prefixes {
+						if p != prefix {
+							kept = append(kept, p)
+						}
+					}
+					if len(kept) == len(prefixes) {
+						SendError(ca, ca.T("prefix.notfound"))
+						return false
+					}
+					if len(kept) == 0 {
+						SendError(ca, ca.T("prefix.last"))
+						return false
+					}
+
+					updateGuildConfig(ca.msg.GuildID, func(gc *guildConfig) {
+						gc.Prefixes = kept
+					})
+					QuickEmbed(ca, QEmbed{content: ca.T("prefix.removed", "prefix", prefix)})
+					return false
+				}},
+			{
+				aliases: []string{"reset"},
+				help: `go back to the default prefixes\n
+				whether prefixes are optional isn't changed`,
+				emptyArg: true,
+				roles:    []string{"botadmin"},
+				callback: func(ca CommandArgs) bool {
+					updateGuildConfig(ca.msg.GuildID, func(gc *guildConfig) {
+						gc.Prefixes = nil
+					})
+					QuickEmbed(ca, QEmbed{content: ca.T("prefix.reset")})
+					return false
+				}},
+			{
+				aliases: []string{"optional"},
+				help: `set whether commands work without a prefix\n
+				^%Pprefix optional off^`,
+				params: []Param{{name: "optional", kind: ParamEnum, choices: []string{"on", "off"}}},
+				roles:  []string{"botadmin"},
+				callback: func(ca CommandArgs) bool {
+					optional := ca.Str("optional") == "on"
+					updateGuildConfig(ca.msg.GuildID, func(gc *guildConfig) {
+						gc.PrefixOptional = &optional
+					})
+
+					content := ca.T("prefix.nowrequired")
+					if optional {
+						content = ca.T("prefix.nowoptional")
+					}
+					QuickEmbed(ca, QEmbed{content: content})
+					return false
+				}},
+		}})
+	RegisterCommand(Command{
+		aliases: []string{"language", "lang"},
+		help: `show or change the language the bot replies in\n
+		^%Planguage set de^`,
+		emptyArg: true,
+		noDM:     true,
+		callback: func(ca CommandArgs) bool {
+			var list []string
+			for _, code := range languageCodes() {
+				list = append(list, fmt.Sprintf("`%s` %s", code, locales[code].name))
+			}
+			lang := GuildLanguage(ca.msg.GuildID)
+			QuickEmbed(ca, QEmbed{title: ca.T("language.title"), content: ca.T("language.current",
+				"language", locales[lang].name, "languages", strings.Join(list, "\n"))})
+			return false
+		},
+		subcommands: []Command{
+			{
+				aliases: []string{"set"},
+				help: `set this server's language\n
+				^%Planguage set de^`,
+				params: []Param{{name: "language", kind: ParamEnum, choices: languageCodes()}},
+				roles:  []string{"botadmin"},
+				callback: func(ca CommandArgs) bool {
+					updateGuildConfig(ca.msg.GuildID, func(gc *guildConfig) {
+						gc.Language = ca.Str("language")
+					})
+					QuickEmbed(ca, QEmbed{content: ca.T("language.set", "language", locales[ca.Str("language")].name)})
+					return false
+				}},
+			{
+				aliases:  []string{"reset"},
+				help:     `go back to the default language`,
+				emptyArg: true,
+				roles:    []string{"botadmin"},
+				callback: func(ca CommandArgs) bool {
+					updateGuildConfig(ca.msg.GuildID, func(gc *guildConfig) {
+						gc.Language = ""
+					})
+					QuickEmbed(ca, QEmbed{content: ca.T("language.set", "language", locales[GuildLanguage(ca.msg.GuildID)].name)})
+					return false
+				}},
+		}})
+}
@@ -0,0 +1,199 @@
+package main
+
+// German
+//	commands for owners aren't translated and show their English help
+var localeDE = &locale{
+	name:   "Deutsch",
+	plural: pluralOne,
+	messages: map[string]string{
+		// help and unknown commands
+		"help.title":            "Befehlshilfe: {command}",
+		"help.usage":            "Verwendung: ^%P{command} {usage}^",
+		"help.subcommands":      "Unterbefehle:",
+		"help.more":             "^%Phelp {command} <Unterbefehl>^ für mehr",
+		"help.aliases":          "andere Namen: {aliases}",
+		"help.notfound":         "Befehl nicht gefunden",
+		"help.notfound.suggest": "Befehl nicht gefunden, meintest du {commands}?",
+		"help.list.title":       "Bot-Befehle",
+		"help.list.optional":    "Befehlspräfixe sind optional!",
+		"help.list.footer":      "\"{prefix}help Befehl\" für Hilfe zu einzelnen Befehlen\n{optional}Präfixe: {prefixes} @{bot}",
+		"suggest.unknown":       "unbekannter Befehl ^{command}^, meintest du {commands}?",
+		"suggest.or":            "{first} oder {last}",
+		"page.footer":           "Seite {page}/{pages}",
+
+		"cooldown.wait.one":   "langsam! versuch es in {n} Sekunde noch einmal",
+		"cooldown.wait.other": "langsam! versuch es in {n} Sekunden noch einmal",
+
+		// prefixes and language
+		"prefix.title":       "Befehlspräfixe",
+		"prefix.required":    "Präfixe sind erforderlich",
+		"prefix.optional":    "Präfixe sind optional",
+		"prefix.exists":      "Präfix gibt es schon",
+		"prefix.added":       "Präfix `{prefix}` hinzugefügt",
+		"prefix.notfound":    "Präfix nicht gefunden",
+		"prefix.last":        "das letzte Präfix kann nicht entfernt werden\n^%Pprefix reset^ stellt die Standardpräfixe wieder her",
+		"prefix.removed":     "Präfix `{prefix}` entfernt",
+		"prefix.reset":       "Präfixe auf Standard zurückgesetzt",
+		"prefix.nowrequired": "Präfixe sind jetzt erforderlich",
+		"prefix.nowoptional": "Präfixe sind jetzt optional",
+		"language.title":     "Sprache",
+		"language.current":   "dieser Server verwendet {language}\n\n{languages}",
+		"language.set":       "Sprache auf {language} gesetzt",
+
+		// arguments
+		"args.missing":          "^{param}^ fehlt",
+		"args.invalid":          "^{param}^ {err}",
+		"args.toomany":          "zu viele Argumente",
+		"args.time":             "ist keine gültige Zeit",
+		"args.int":              "muss eine ganze Zahl sein",
+		"args.number":           "muss eine Zahl sein",
+		"args.range":            "muss zwischen {min} und {max} liegen",
+		"args.user":             "muss eine Benutzererwähnung sein",
+		"args.user.notfound":    "Benutzer nicht gefunden",
+		"args.role.dm":          "Rollen gibt es nur auf einem Server",
+		"args.role.notfound":    "Rolle nicht gefunden",
+		"args.channel":          "muss eine Kanalerwähnung sein",
+		"args.channel.notfound": "Kanal nicht gefunden",
+		"args.choices":          "muss eins davon sein: {choices}",
+		"args.format":           "hat nicht das richtige Format",
+
+		// slash commands
+		"slash.noaccess":  "du kannst diesen Befehl hier nicht benutzen",
+		"slash.moduleoff": "das Modul {module} ist hier ausgeschaltet",
+
+		// modules and permissions
+		"modules.title":      "Module",
+		"modules.on":         "an",
+		"modules.off":        "aus",
+		"modules.onhere":     "{status}, in diesem Kanal an",
+		"modules.offhere":    "{status}, in diesem Kanal aus",
+		"modules.enabled":    "`{module}` in {where} eingeschaltet",
+		"modules.disabled":   "`{module}` in {where} ausgeschaltet",
+		"modules.reset":      "`{module}` in {where} zurückgesetzt",
+		"modules.server":     "diesem Server",
+		"modules.otherguild": "der Kanal muss auf diesem Server sein",
+		"perms.title":        "Berechtigungen",
+		"perms.none":         "keine Regeln gesetzt, es gelten die Standards",
+		"perms.unknown":      "unbekannter Knoten\nsiehe ^%Pperms nodes^",
+		"perms.target":       "das Ziel muss eine Rolle, ein Benutzer oder der Name einer Berechtigung sein",
+		"perms.granted":      "`{node}` an {target} vergeben",
+		"perms.revoked":      "`{node}` von {target} entzogen",
+		"perms.norule":       "für diesen Knoten ist keine Regel gesetzt",
+		"perms.reset":        "`{node}` auf den Standard zurückgesetzt",
+		"perms.nodes":        "Berechtigungsknoten",
+
+		// owner commands
+		"reload.title":     "Konfiguration neu geladen",
+		"reload.unchanged": "nichts geändert",
+		"reload.changed":   "geändert: {fields}",
+		"reload.restart":   "braucht einen Neustart: {fields}",
+		"stats.title":      "Laufzeitstatistik",
+		"stats.cache":      "`{cache}: {entries} im Cache, {hits} Treffer, {misses} Fehlschläge`",
+		"stats.roles":      "Rollen",
+		"stats.users":      "Benutzer",
+		"stats.thisshard":  "`dies ist Shard {shard}`",
+		"stats.shard":      "`Shard {shard}: {guilds} Server, {latency}ms`",
+		"stats.shard.down": "`Shard {shard}: {guilds} Server, getrennt`",
+
+		// rpg-clocks
+		"clock.notfound":     "Uhr nicht gefunden",
+		"clock.none":         "keine Uhren auf diesem Server",
+		"clock.styleset":     "Uhrenstil gesetzt",
+		"clock.deleted":      "`{name} ({ticked}/{slices})` gelöscht",
+		"clock.error.create": "Fehler beim Erstellen der Uhr: {err}",
+		"clock.error.style":  "Uhrenstil konnte nicht gespeichert werden: {err}",
+		"clock.error.save":   "Uhr konnte nicht gespeichert werden: {err}",
+		"clock.error.slices": "Anzahl der Segmente ist ungültig",
+		"clock.error.ticked": "Anzahl der gefüllten Segmente ist ungültig",
+		"clock.error.size":   "braucht mindestens ein Segment und nicht mehr gefüllte als Segmente",
+
+		// rpg-roll
+		"roll.title":          "Wurf von {name}",
+		"roll.nogm":           "kein SL auf diesem Server gefunden",
+		"roll.error.noexpr":   "keine gültigen Würfelausdrücke gefunden",
+		"roll.error.modifier": "Modifikator ist ungültig: {err}",
+		"roll.error.syntax":   "zu wenige Angaben im Würfelausdruck",
+		"roll.error.dice":     "Anzahl der Würfel ist ungültig: {err}",
+		"roll.error.faces":    "Würfelseiten sind ungültig: {err}",
+		"roll.error.nothing":  "nichts zu würfeln",
+		"roll.error.toobig":   "Wahrscheinlichkeit zu groß zum Berechnen",
+		"roll.error.gm":       "Fehler beim Suchen des SL: {err}",
+		"roll.error.dmgm":     "Fehler beim Schreiben an den SL: {err}",
+		"roll.error.dmuser":   "Fehler beim Schreiben an den Benutzer: {err}",
+		"seed.title":          "Würfel neu initialisiert",
+		"seed.current":        "aktueller Seed: {seed}",
+		"seed.new":            "neuer Seed: {seed}",
+		"seed.hashed":         "\"{seed}\" wurde zu einer Zahl gehasht und\nmit der Zeit verrechnet, um Manipulation zu erschweren",
+
+		// music
+		"music.idle.title":    "kein Lied läuft",
+		"music.idle":          "füge einen Link zu einem Lied ein, um zu beginnen",
+		"music.queuedby":      "eingereiht von `{user}`",
+		"music.time":          "aktuelle Zeit: {current} / {length}",
+		"music.updates.one":   "aktualisiert jede Sekunde",
+		"music.updates.other": "aktualisiert alle {n}s",
+		"music.volume":        "Lautstärke: {volume}",
+		"music.queued.one":    "{n} Lied in der Warteschlange",
+		"music.queued.other":  "{n} Lieder in der Warteschlange",
+		"music.more.one":      "…und {n} weiterer, ^%Pqueue^ zeigt die ganze Warteschlange",
+		"music.more.other":    "…und {n} weitere, ^%Pqueue^ zeigt die ganze Warteschlange",
+		"music.queue.empty":   "die Warteschlange ist leer",
+		"music.looping":       "(Wiederholung)",
+		"music.paused":        "(pausiert)",
+		"music.notinvoice":    "Benutzer ist in keinem sichtbaren Sprachkanal",
+		"music.otherchannel":  "spielt schon in einem anderen Kanal",
+		"music.badlink":       "kein erlaubter Link",
+		"music.nostreams":     "Streams sind nicht erlaubt",
+		"music.noposition":    "kein Lied an dieser Position",
+		"music.playing":       "das Lied läuft gerade, überspringe es stattdessen",
+		"music.error.query":   "Fehler beim Abfragen des Lieds: {err}",
+		"music.error.ffmpeg":  "Fehler in der ffmpeg-Sitzung: {err}",
+		"music.error.embed":   "Embed konnte nicht erstellt werden: {err}",
+
+		// help for commands, see commandHelp
+		"cmd.prefix":                  "Befehlspräfixe dieses Servers anzeigen oder ändern\n\nPräfixe können mehrere Zeichen haben, z.B. ^tb!^\nden Bot zu erwähnen funktioniert immer als Präfix",
+		"cmd.prefix add":              "ein Befehlspräfix hinzufügen\n\n^%Pprefix add tb!^",
+		"cmd.prefix add.prefix":       "bis zu 10 Zeichen, keine Leerzeichen",
+		"cmd.prefix remove":           "ein Befehlspräfix entfernen\n\n^%Pprefix remove !^",
+		"cmd.prefix reset":            "zu den Standardpräfixen zurückkehren\n\nob Präfixe optional sind, bleibt unverändert",
+		"cmd.prefix optional":         "festlegen, ob Befehle ohne Präfix funktionieren\n\n^%Pprefix optional off^",
+		"cmd.language":                "die Sprache des Bots anzeigen oder ändern\n\n^%Planguage set en^",
+		"cmd.language set":            "die Sprache dieses Servers festlegen\n\n^%Planguage set en^",
+		"cmd.language reset":          "zur Standardsprache zurückkehren",
+		"cmd.modules":                 "anzeigen oder ändern, welche Befehle auf diesem Server funktionieren\n\n^%Pmodules disable music^\n^%Pmodules enable music #music^",
+		"cmd.modules enable":          "ein Modul auf dem Server oder in einem Kanal einschalten\n\n^%Pmodules enable music #music^",
+		"cmd.modules enable.channel":  "nur in diesem Kanal ändern",
+		"cmd.modules disable":         "ein Modul auf dem Server oder in einem Kanal ausschalten\n\n^%Pmodules disable rpg-clocks^",
+		"cmd.modules disable.channel": "nur in diesem Kanal ändern",
+		"cmd.modules reset":           "ein Modul auf den Standard zurücksetzen\n\nein Kanal übernimmt wieder die Einstellung des Servers",
+		"cmd.modules reset.channel":   "nur in diesem Kanal ändern",
+		"cmd.perms":                   "anzeigen oder ändern, wer Befehle auf diesem Server benutzen darf\n\neine Regel für einen Knoten ersetzt den Standard einer gleichnamigen Rolle\n^%Pperms grant gm @Storyteller^\n^%Pperms grant roll @someone^\n^%Pperms grant botadmin manageserver^",
+		"cmd.perms grant":             "einer Rolle, einem Benutzer oder einer Discord-Berechtigung einen Knoten geben\n\n^%Pperms grant clock.create @Storyteller^",
+		"cmd.perms grant.node":        "Befehl wie ^clock.create^ oder Rollenknoten wie ^gm^",
+		"cmd.perms grant.target":      "Rolle, Benutzer oder Name einer Berechtigung",
+		"cmd.perms revoke":            "einer Rolle, einem Benutzer oder einer Discord-Berechtigung einen Knoten entziehen\n\n^%Pperms revoke clock.create @Storyteller^",
+		"cmd.perms revoke.node":       "Befehl wie ^clock.create^ oder Rollenknoten wie ^gm^",
+		"cmd.perms revoke.target":     "Rolle, Benutzer oder Name einer Berechtigung",
+		"cmd.perms reset":             "die Regel eines Knotens entfernen und zum Standard zurückkehren\n\n^%Pperms reset gm^",
+		"cmd.perms reset.node":        "Befehl wie ^clock.create^ oder Rollenknoten wie ^gm^",
+		"cmd.perms nodes":             "Knoten auflisten, für die Regeln gesetzt werden können",
+		"cmd.play":                    "ein Lied von einer URL abspielen\n\nder Befehl ist optional: du kannst einfach eine URL einfügen\n\n^%Pplay https://www.youtube.com/watch?v=asdf123^\n^https://www.youtube.com/watch?v=asdf123^",
+		"cmd.setmusic":                "diesen Kanal als Musikkanal festlegen\n\nder Bot hört nur in diesem Kanal auf Wünsche\nalle Musikausgaben erscheinen in diesem Kanal\n^%Psetmusic^ noch einmal erstellt das Embed neu",
+		"cmd.volume":                  "Lautstärke ändern\n\n^%Pvolume 0.5^",
+		"cmd.volume.volume":           "von 0.1 bis 1.5",
+		"cmd.seek":                    "im aktuellen Lied springen\n\n^%Pseek 30^\n^%Pseek 1m30s^\n^%Pseek 1:30^",
+		"cmd.queue":                   "die Warteschlange verwalten",
+		"cmd.queue remove":            "ein Lied aus der Warteschlange entfernen\n\n^%Pqueue remove 3^",
+		"cmd.queue remove.position":   "Position des Lieds in der Warteschlange",
+		"cmd.queue clear":             "alle kommenden Lieder aus der Warteschlange entfernen",
+		"cmd.clockstyle":              "Uhrenstil festlegen\n\ngültige Stile:\n - ^circle^\n - ^spikes^",
+		"cmd.clock":                   "eine Uhr anzeigen oder bearbeiten\n\n^%Pclock etwas passiert^ - eine Uhr nach Namen anzeigen\n^%Pclock etwas^ - eine Uhr nach einem Teil des Namens anzeigen",
+		"cmd.clock create":            "eine Uhr erstellen oder ändern\n\n^%Pclock create 4 Name^ - Uhr mit 4 Segmenten erstellen\n^%Pclock create 1/4 Name^ - Uhr mit 1/4 Segmenten erstellen oder ändern",
+		"cmd.clock create.size":       "Anzahl der Segmente, oder gefüllt/Segmente",
+		"cmd.clock tick":              "eine Uhr vor- oder zurückstellen\n\n^%Pclock tick +2 Name^ - Uhr um 2 Segmente erhöhen\n^%Pclock tick -1 Name^ - Uhr um 1 Segment verringern",
+		"cmd.clock delete":            "eine Uhr löschen\n\n^%Pclock delete Name^",
+		"cmd.clocks":                  "alle Uhren anzeigen",
+		"cmd.roll":                    "Würfel mit realistischer Wahrscheinlichkeit werfen\n\n^%Proll d6^ - einen Würfel mit 6 Seiten werfen\n^%Proll 2d6^ - 2 Würfel mit 6 Seiten werfen\n^%Proll d6 d6^ - mehrere Würfel werfen\n^%Proll d6+d6^ - Würfel werfen und addieren\n^%Proll d6-d6^ - Würfel werfen und subtrahieren\n^%Proll d6-1^ - Würfel mit Modifikator werfen\n^%Proll 2d6!^ - 2d6 mit explodierenden Würfeln werfen\n^%Proll 2d6b^ - 2d6 als Vorteilswurf werfen (höchste Zahl zählt)\n^%Proll gm 2d6^ - Wurf, den nur du und der SL sehen\n^%Proll 2d6 riskant standard^ - einen Wurf markieren",
+		"cmd.seed":                    "den Zufalls-Seed anzeigen oder ändern\n\n^%Pseed^ - aktuellen Seed anzeigen\n^%Pseed asdf^ - Seed auf \"asdf\" ändern",
+	},
+}
@@ -0,0 +1,154 @@
+package main
+
+// English, what every other language falls back to
+//	help text isn't here, English help is in each Command
+var localeEN = &locale{
+	name:   "English",
+	plural: pluralOne,
+	messages: map[string]string{
+		// help and unknown commands
+		"help.title":            "command help: {command}",
+		"help.usage":            "usage: ^%P{command} {usage}^",
+		"help.subcommands":      "subcommands:",
+		"help.more":             "^%Phelp {command} <subcommand>^ for more",
+		"help.aliases":          "other aliases: {aliases}",
+		"help.notfound":         "command not found",
+		"help.notfound.suggest": "command not found, did you mean {commands}?",
+		"help.list.title":       "bot commands",
+		"help.list.optional":    "command prefixes are optional!",
+		"help.list.footer":      "\"{prefix}help command\" for help with individual commands\n{optional}prefixes: {prefixes} @{bot}",
+		"suggest.unknown":       "unknown command ^{command}^, did you mean {commands}?",
+		"suggest.or":            "{first} or {last}",
+		"page.footer":           "page {page}/{pages}",
+
+		"cooldown.wait.one":   "slow down! try again in {n} second",
+		"cooldown.wait.other": "slow down! try again in {n} seconds",
+
+		// prefixes and language
+		"prefix.title":       "command prefixes",
+		"prefix.required":    "prefixes are required",
+		"prefix.optional":    "prefixes are optional",
+		"prefix.exists":      "prefix already exists",
+		"prefix.added":       "prefix `{prefix}` added",
+		"prefix.notfound":    "prefix not found",
+		"prefix.last":        "can't remove the last prefix\nuse ^%Pprefix reset^ to go back to the defaults",
+		"prefix.removed":     "prefix `{prefix}` removed",
+		"prefix.reset":       "prefixes reset to defaults",
+		"prefix.nowrequired": "prefixes are now required",
+		"prefix.nowoptional": "prefixes are now optional",
+		"language.title":     "language",
+		"language.current":   "this server uses {language}\n\n{languages}",
+		"language.set":       "language set to {language}",
+
+		// arguments
+		"args.missing":          "missing ^{param}^",
+		"args.invalid":          "^{param}^ {err}",
+		"args.toomany":          "too many arguments",
+		"args.time":             "not a valid time",
+		"args.int":              "must be a whole number",
+		"args.number":           "must be a number",
+		"args.range":            "must be between {min} and {max}",
+		"args.user":             "must be a user mention",
+		"args.user.notfound":    "user not found",
+		"args.role.dm":          "roles can only be used in a server",
+		"args.role.notfound":    "role not found",
+		"args.channel":          "must be a channel mention",
+		"args.channel.notfound": "channel not found",
+		"args.choices":          "must be one of: {choices}",
+		"args.format":           "is not in the right format",
+
+		// slash commands
+		"slash.noaccess":  "you can't use this command here",
+		"slash.moduleoff": "the {module} module is turned off here",
+
+		// modules and permissions
+		"modules.title":      "modules",
+		"modules.on":         "on",
+		"modules.off":        "off",
+		"modules.onhere":     "{status}, on in this channel",
+		"modules.offhere":    "{status}, off in this channel",
+		"modules.enabled":    "`{module}` enabled in {where}",
+		"modules.disabled":   "`{module}` disabled in {where}",
+		"modules.reset":      "`{module}` reset in {where}",
+		"modules.server":     "this server",
+		"modules.otherguild": "channel must be in this server",
+		"perms.title":        "permissions",
+		"perms.none":         "no rules set, using defaults",
+		"perms.unknown":      "unknown node\nsee ^%Pperms nodes^",
+		"perms.target":       "target must be a role, user or permission name",
+		"perms.granted":      "granted `{node}` to {target}",
+		"perms.revoked":      "revoked `{node}` from {target}",
+		"perms.norule":       "no rule set for that node",
+		"perms.reset":        "`{node}` reset to default",
+		"perms.nodes":        "permission nodes",
+
+		// owner commands
+		"reload.title":     "config reloaded",
+		"reload.unchanged": "nothing changed",
+		"reload.changed":   "changed: {fields}",
+		"reload.restart":   "needs a restart: {fields}",
+		"stats.title":      "runtime stats",
+		"stats.runtime":    "`alloc: {alloc}MB`\n`stack: {stack}MB`\n`pause: {pause}ms`\n`numgo: {goroutines}`\n`guilds: {guilds}`",
+		"stats.cache":      "`{cache}: {entries} cached, {hits} hits, {misses} misses`",
+		"stats.roles":      "roles",
+		"stats.users":      "users",
+		"stats.thisshard":  "`this is shard {shard}`",
+		"stats.shard":      "`shard {shard}: {guilds} guilds, {latency}ms`",
+		"stats.shard.down": "`shard {shard}: {guilds} guilds, disconnected`",
+
+		// rpg-clocks
+		"clock.notfound":     "clock not found",
+		"clock.none":         "no clocks in this guild",
+		"clock.styleset":     "clock style set",
+		"clock.deleted":      "`{name} ({ticked}/{slices})` deleted",
+		"clock.error.create": "error creating clock: {err}",
+		"clock.error.style":  "couldn't save clock style: {err}",
+		"clock.error.save":   "couldn't save clock: {err}",
+		"clock.error.slices": "couldn't parse slice count",
+		"clock.error.ticked": "couldn't parse ticked count",
+		"clock.error.size":   "needs at least one slice and no more ticked than slices",
+
+		// rpg-roll
+		"roll.title":          "roll by {name}",
+		"roll.nogm":           "no gm found in this server",
+		"roll.error.noexpr":   "no valid roll expressions found",
+		"roll.error.modifier": "could not parse modifier: {err}",
+		"roll.error.syntax":   "not enough parameters in roll syntax",
+		"roll.error.dice":     "couldn't parse number of dice: {err}",
+		"roll.error.faces":    "couldn't parse dice value: {err}",
+		"roll.error.nothing":  "nothing to roll",
+		"roll.error.toobig":   "probability too high to compute",
+		"roll.error.gm":       "error finding gm: {err}",
+		"roll.error.dmgm":     "error DMing gm: {err}",
+		"roll.error.dmuser":   "error DMing user: {err}",
+		"seed.title":          "roll reseeded",
+		"seed.current":        "current seed: {seed}",
+		"seed.new":            "new seed: {seed}",
+		"seed.hashed":         "\"{seed}\" hashed to numerical value and\nmodulated by time to mitigate manipulation",
+
+		// music
+		"music.idle.title":    "no song playing",
+		"music.idle":          "paste in a song link to begin",
+		"music.queuedby":      "queued by `{user}`",
+		"music.time":          "current time: {current} / {length}",
+		"music.updates.one":   "updates every second",
+		"music.updates.other": "updates every {n}s",
+		"music.volume":        "volume: {volume}",
+		"music.queued.one":    "{n} song in queue",
+		"music.queued.other":  "{n} songs in queue",
+		"music.more.one":      "…and {n} more, ^%Pqueue^ to see the whole queue",
+		"music.more.other":    "…and {n} more, ^%Pqueue^ to see the whole queue",
+		"music.queue.empty":   "the queue is empty",
+		"music.looping":       "(looping)",
+		"music.paused":        "(paused)",
+		"music.notinvoice":    "user not in a visible voice channel",
+		"music.otherchannel":  "already playing in a different channel",
+		"music.badlink":       "not an allowed link",
+		"music.nostreams":     "no streams allowed",
+		"music.noposition":    "no song at that position",
+		"music.playing":       "song is currently playing, skip it instead",
+		"music.error.query":   "error querying song: {err}",
+		"music.error.ffmpeg":  "ffmpeg session error: {err}",
+		"music.error.embed":   "couldn't create embed: {err}",
+	},
+}
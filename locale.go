@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// message catalog for user-facing text
+//	messages are looked up by ID in the guild's language, then in English
+//	- {name} placeholders are filled in by T and TN
+//	- %P and ^ are left alone, formatTokens handles them when the message is sent
+//	- plurals are separate messages, id.one and id.other, picked by TN
+//	English help stays in each Command, other languages can override it
+//	with "cmd.<command path>" and "cmd.<command path>.<param name>"
+
+var localeLog = NewLogger("locale")
+
+// the language messages fall back to, and the default if config.json doesn't set one
+var defaultLanguage = "en"
+
+type locale struct {
+	// name of the language in itself, for the language command
+	name string
+
+	// returns "one" or "other" for a count
+	plural func(n int) string
+
+	messages map[string]string
+}
+
+var locales = map[string]*locale{
+	"en": localeEN,
+	"de": localeDE,
+}
+
+// plural rule for languages that only have a singular for exactly 1, like English and German
+func pluralOne(n int) string {
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+// languageCodes returns the bundled languages, sorted
+func languageCodes() []string {
+	var codes []string
+	for code := range locales {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// GuildLanguage returns a guild's language, or the global one
+func GuildLanguage(gid string) string {
+	guildConfigMutex.Lock()
+	gc, ok := guildConfigs[gid]
+	lang := ""
+	if ok {
+		lang = gc.Language
+	}
+	guildConfigMutex.Unlock()
+
+	if _, ok := locales[lang]; ok {
+		return lang
+	}
+	if _, ok := locales[Config().Language]; ok {
+		return Config().Language
+	}
+	return defaultLanguage
+}
+
+// finds a message in a language, or in English
+func findMessage(lang string, id string) (string, bool) {
+	if msg, ok := locales[lang].messages[id]; ok {
+		return msg, true
+	}
+	msg, ok := locales[defaultLanguage].messages[id]
+	return msg, ok
+}
+
+// T returns a message in a guild's language with placeholders filled from key, value pairs
+//	T(gid, "clock.deleted", "name", cl.Name)
+//	unknown IDs are returned as they are, so a missing message is obvious
+func T(gid string, id string, kv ...interface{}) string {
+	msg, ok := findMessage(GuildLanguage(gid), id)
+	if !ok {
+		localeLog.Warn("missing message", "id", id)
+		return id
+	}
+	return fillPlaceholders(msg, kv)
+}
+
+// TN is T for a message about a count, using the plural form for n
+//	n fills the {n} placeholder
+func TN(gid string, id string, n int, kv ...interface{}) string {
+	lang := GuildLanguage(gid)
+	msg, ok := findMessage(lang, id+"."+locales[lang].plural(n))
+	if !ok {
+		msg, ok = findMessage(lang, id+".other")
+	}
+	if !ok {
+		localeLog.Warn("missing message", "id", id)
+		return id
+	}
+	return fillPlaceholders(msg, append([]interface{}{"n", n}, kv...))
+}
+
+// replaces every placeholder in one pass so values can't be substituted again
+func fillPlaceholders(msg string, kv []interface{}) string {
+	if len(kv) < 2 {
+		return msg
+	}
+	var pairs []string
+	for i := 0; i+1 < len(kv); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("{%v}", kv[i]), fmt.Sprint(kv[i+1]))
+	}
+	return strings.NewReplacer(pairs...).Replace(msg)
+}
+
+// T returns a message in the language of the guild ca is in
+func (ca CommandArgs) T(id string, kv ...interface{}) string {
+	return T(ca.guildID(), id, kv...)
+}
+
+// TN returns a counted message in the language of the guild ca is in
+func (ca CommandArgs) TN(id string, n int, kv ...interface{}) string {
+	return TN(ca.guildID(), id, n, kv...)
+}
+
+// commandHelp returns a command's help in a guild's language, or its English help
+func commandHelp(cmd Command, gid string) string {
+	if msg, ok := locales[GuildLanguage(gid)].messages["cmd."+cmd.path]; ok {
+		return msg
+	}
+	return cmd.help
+}
+
+// paramHelp returns a param's help in a guild's language, or its English help
+func paramHelp(cmd Command, p Param, gid string) string {
+	if msg, ok := locales[GuildLanguage(gid)].messages["cmd."+cmd.path+"."+p.name]; ok {
+		return msg
+	}
+	return p.help
+}
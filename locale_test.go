@@ -0,0 +1,68 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var placeholderRx = regexp.MustCompile(`\{\w+\}`)
+
+// every translated message has an English one with the same placeholders
+func TestLocaleCatalog(t *testing.T) {
+	en := locales[defaultLanguage].messages
+	for code, loc := range locales {
+		for id, msg := range loc.messages {
+			if strings.HasPrefix(id, "cmd.") {
+				continue
+			}
+			base, ok := en[id]
+			if !ok {
+				t.Errorf("%s: %s isn't in English", code, id)
+				continue
+			}
+			if got, want := placeholders(msg), placeholders(base); got != want {
+				t.Errorf("%s: %s has placeholders %s, English has %s", code, id, got, want)
+			}
+		}
+	}
+}
+
+func placeholders(msg string) string {
+	found := placeholderRx.FindAllString(msg, -1)
+	sort.Strings(found)
+	return strings.Join(found, " ")
+}
+
+func TestGermanReplies(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		content string
+		want    string
+	}{
+		{"bad param", testGM.ID, "!clockstyle squares", "`style` muss eins davon sein"},
+		{"too many", testGM.ID, "!clockstyle spikes circle", "zu viele Argumente"},
+		{"modules", testAdmin.ID, "!modules", "an"},
+		{"module off", testAdmin.ID, "!modules disable music", "in diesem Server ausgeschaltet"},
+		{"perms", testAdmin.ID, "!perms", "keine Regeln gesetzt"},
+		{"unknown node", testAdmin.ID, "!perms grant nothing @x", "unbekannter Knoten"},
+	}
+	users := map[string]*discordgo.User{testGM.ID: testGM, testAdmin.ID: testAdmin}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestBot(t)
+			f.Receive(testTable, testAdmin, "!language set de")
+			f.Receive(testTable, users[tt.user], tt.content)
+
+			m := lastSent(t, f, testTable)
+			if !strings.Contains(embedText(m), tt.want) {
+				t.Errorf("reply %q doesn't contain %q", embedText(m), tt.want)
+			}
+		})
+	}
+}
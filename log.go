@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogLevel is how important a log line is
+type LogLevel int
+
+// log levels
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var logLevelNames = map[LogLevel]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func parseLogLevel(str string) (LogLevel, error) {
+	for lvl, name := range logLevelNames {
+		if strings.EqualFold(name, str) {
+			return lvl, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", str)
+}
+
+// log file rotation when config.json doesn't set it
+var defaultLogMaxSize = 10
+var defaultLogMaxFiles = 5
+
+// logConfig is the "log" section of config.json
+//	- level is the default level, subsystems overrides it per subsystem
+//	- format is "text" or "json"
+//	- file is written to as well as stdout if set, and is rotated when it
+//		gets bigger than maxsize MB, keeping maxfiles old files
+//		(10MB and 5 files if they aren't set)
+type logConfig struct {
+	Level      string
+	Format     string
+	File       string
+	MaxSize    int
+	MaxFiles   int
+	Subsystems map[string]string
+}
+
+func validateLogConfig(lc logConfig) error {
+	if lc.Level != "" {
+		if _, err := parseLogLevel(lc.Level); err != nil {
+			return err
+		}
+	}
+	for sub, lvl := range lc.Subsystems {
+		if _, err := parseLogLevel(lvl); err != nil {
+			return fmt.Errorf("subsystem %s: %w", sub, err)
+		}
+	}
+	if lc.Format != "" && lc.Format != "text" && lc.Format != "json" {
+		return fmt.Errorf("unknown log format %q", lc.Format)
+	}
+	return nil
+}
+
+var logMutex sync.Mutex
+var logLevel = LevelInfo
+var logSubsystemLevels = make(map[string]LogLevel)
+var logJSON = false
+var logOut io.Writer = os.Stdout
+var logFile *rotatingFile
+
+// configureLogging applies the log section of config.json
+//	must be validated first
+func configureLogging(lc logConfig) error {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
+	logLevel = LevelInfo
+	if lc.Level != "" {
+		logLevel, _ = parseLogLevel(lc.Level)
+	}
+	logSubsystemLevels = make(map[string]LogLevel)
+	for sub, lvl := range lc.Subsystems {
+		logSubsystemLevels[sub], _ = parseLogLevel(lvl)
+	}
+	logJSON = lc.Format == "json"
+
+	if logFile != nil && logFile.path != lc.File {
+		logFile.Close()
+		logFile = nil
+	}
+	if lc.File != "" && logFile == nil {
+		f, err := openRotatingFile(lc.File)
+		if err != nil {
+			logOut = os.Stdout
+			return err
+		}
+		logFile = f
+	}
+	if logFile != nil {
+		// unset means the defaults, only values that were given are clamped
+		maxSize, maxFiles := defaultLogMaxSize, defaultLogMaxFiles
+		if lc.MaxSize != 0 {
+			maxSize = ClampI(lc.MaxSize, 1, 1024)
+		}
+		if lc.MaxFiles != 0 {
+			maxFiles = ClampI(lc.MaxFiles, 1, 100)
+		}
+		logFile.Lock()
+		logFile.maxSize = int64(maxSize) * 1024 * 1024
+		logFile.maxFiles = maxFiles
+		logFile.Unlock()
+		logOut = io.MultiWriter(os.Stdout, logFile)
+	} else {
+		logOut = os.Stdout
+	}
+	return nil
+}
+
+// Logger writes leveled log lines for a subsystem with context fields
+//	the zero Logger logs without a subsystem
+type Logger struct {
+	subsystem string
+	keys      []string
+	values    []interface{}
+}
+
+// NewLogger returns a logger for a subsystem, ie "music" or "ffmpeg"
+//	each subsystem's verbosity can be set in config.json
+func NewLogger(subsystem string) Logger {
+	return Logger{subsystem: subsystem}
+}
+
+// With returns a logger that adds a field to every line
+func (l Logger) With(key string, value interface{}) Logger {
+	return Logger{
+		subsystem: l.subsystem,
+		keys:      append(append([]string{}, l.keys...), key),
+		values:    append(append([]interface{}{}, l.values...), value),
+	}
+}
+
+// WithCommand returns a logger with the guild, channel, user and command of ca
+func (l Logger) WithCommand(ca CommandArgs) Logger {
+	if ca.msg != nil {
+		if ca.msg.GuildID != "" {
+			l = l.With("guild", ca.msg.GuildID)
+		}
+		l = l.With("channel", ca.msg.ChannelID)
+		if ca.msg.Author != nil {
+			l = l.With("user", ca.msg.Author.ID)
+		}
+	}
+	if st, ok := ca.sess.(sessionTransport); ok && st.ShardCount > 1 {
+		l = l.With("shard", st.ShardID)
+	}
+	if ca.cmd != nil {
+		l = l.With("command", ca.cmd.path)
+	}
+	return l
+}
+
+// Enabled returns whether lines at a level would be written
+func (l Logger) Enabled(lvl LogLevel) bool {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
+	min, ok := logSubsystemLevels[l.subsystem]
+	if !ok {
+		min = logLevel
+	}
+	return lvl >= min
+}
+
+// Debug logs a message with key value pairs
+func (l Logger) Debug(msg string, kv ...interface{}) { l.log(LevelDebug, msg, kv) }
+
+// Info logs a message with key value pairs
+func (l Logger) Info(msg string, kv ...interface{}) { l.log(LevelInfo, msg, kv) }
+
+// Warn logs a message with key value pairs
+func (l Logger) Warn(msg string, kv ...interface{}) { l.log(LevelWarn, msg, kv) }
+
+// Error logs a message with key value pairs
+func (l Logger) Error(msg string, kv ...interface{}) { l.log(LevelError, msg, kv) }
+
+func (l Logger) log(lvl LogLevel, msg string, kv []interface{}) {
+	if !l.Enabled(lvl) {
+		return
+	}
+
+	keys := append([]string{}, l.keys...)
+	values := append([]interface{}{}, l.values...)
+	for i := 0; i < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		var val interface{} = "(missing)"
+		if i+1 < len(kv) {
+			val = kv[i+1]
+		}
+		// errors marshal to {} in json
+		if err, ok := val.(error); ok {
+			val = err.Error()
+		}
+		keys = append(keys, key)
+		values = append(values, val)
+	}
+
+	now := time.Now()
+	var line string
+	if logJSONEnabled() {
+		line = formatLogJSON(now, lvl, l.subsystem, msg, keys, values)
+	} else {
+		line = formatLogText(now, lvl, l.subsystem, msg, keys, values)
+	}
+
+	logMutex.Lock()
+	io.WriteString(logOut, line)
+	logMutex.Unlock()
+}
+
+func logJSONEnabled() bool {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	return logJSON
+}
+
+func formatLogText(now time.Time, lvl LogLevel, sub string, msg string, keys []string, values []interface{}) string {
+	var b strings.Builder
+	b.WriteString(now.Format("2006-01-02 15:04:05.000"))
+	b.WriteString(" ")
+	b.WriteString(strings.ToUpper(fmt.Sprintf("%-5s", logLevelNames[lvl])))
+	if sub != "" {
+		fmt.Fprintf(&b, " [%s]", sub)
+	}
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i, k := range keys {
+		v := fmt.Sprint(values[i])
+		if strings.ContainsAny(v, " \t\n\"=") || v == "" {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&b, " %s=%s", k, v)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func formatLogJSON(now time.Time, lvl LogLevel, sub string, msg string, keys []string, values []interface{}) string {
+	// fields are written in order so lines read the same as text ones
+	var b strings.Builder
+	b.WriteString("{")
+	writeField := func(k string, v interface{}) {
+		if b.Len() > 1 {
+			b.WriteString(",")
+		}
+		kb, _ := json.Marshal(k)
+		vb, err := json.Marshal(v)
+		if err != nil {
+			vb, _ = json.Marshal(fmt.Sprint(v))
+		}
+		b.Write(kb)
+		b.WriteString(":")
+		b.Write(vb)
+	}
+
+	writeField("time", now.Format(time.RFC3339Nano))
+	writeField("level", logLevelNames[lvl])
+	if sub != "" {
+		writeField("subsystem", sub)
+	}
+	writeField("msg", msg)
+	for i, k := range keys {
+		writeField(k, values[i])
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// rotatingFile is a log file that's moved to path.1, path.2... when it gets too big
+type rotatingFile struct {
+	sync.Mutex
+	path     string
+	file     *os.File
+	size     int64
+	maxSize  int64
+	maxFiles int
+}
+
+func openRotatingFile(path string) (*rotatingFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("couldn't stat log file: %w", err)
+	}
+	return &rotatingFile{path: path, file: f, size: info.Size(), maxSize: int64(defaultLogMaxSize) * 1024 * 1024, maxFiles: defaultLogMaxFiles}, nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size+int64(len(p)) > r.maxSize && r.size > 0 {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// must be called with r locked
+func (r *rotatingFile) rotate() error {
+	r.file.Close()
+	os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	os.Rename(r.path, r.path+".1")
+
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		r.file = nil
+		return fmt.Errorf("couldn't reopen log file: %w", err)
+	}
+	r.file = f
+	r.size = 0
+	return nil
+}
+
+func (r *rotatingFile) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// lookups that try state first and fall back to REST or the gateway
+//	state only has what discord sent since connecting, so it can be missing
+//	guilds and channels after a reconnect, and large guilds only send
+//	online members in GUILD_CREATE
+//	guild lookups go through the shard that owns the guild, whichever shard sess is
+
+var lookupLog = NewLogger("lookup")
+
+// how long to wait for the gateway to send a guild's members
+var memberRequestTimeout = 10 * time.Second
+
+// how long before a guild's members are requested again
+//	GUILD_MEMBER_ADD and REMOVE keep state up to date in between
+var memberRequestInterval = 30 * time.Minute
+
+// a request for a guild's members
+//	at is zero while it's running and set once it succeeds
+type memberRequest struct {
+	done chan bool
+	at   time.Time
+}
+
+var memberRequestMutex sync.Mutex
+var memberRequests = make(map[string]*memberRequest)
+
+// lookupGuild returns a guild from state, or gets it with REST and adds it to state
+func lookupGuild(sess Transport, gid string) (*discordgo.Guild, error) {
+	sess = guildTransport(sess, gid)
+	g, err := sess.State().Guild(gid)
+	if err == nil {
+		return g, nil
+	}
+
+	g, err = sess.Guild(gid)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find guild: %w", err)
+	}
+	err = sess.State().GuildAdd(g)
+	if err != nil {
+		lookupLog.Warn("couldn't add guild to state", "guild", gid, "err", err)
+		return g, nil
+	}
+	return sess.State().Guild(gid)
+}
+
+// lookupChannel returns a channel from state, or gets it with REST and adds it to state
+func lookupChannel(sess Transport, chid string) (*discordgo.Channel, error) {
+	ch, err := sess.State().Channel(chid)
+	if err == nil {
+		return ch, nil
+	}
+
+	ch, err = sess.Channel(chid)
+	if err != nil {
+		return nil, err
+	}
+	if ch.GuildID != "" {
+		// the guild has to be in state before its channels can be
+		lookupGuild(sess, ch.GuildID)
+	}
+	sess.State().ChannelAdd(ch)
+	return ch, nil
+}
+
+// lookupRoles returns a copy of a guild's roles from state, or from REST
+func lookupRoles(sess Transport, gid string) ([]*discordgo.Role, error) {
+	sess = guildTransport(sess, gid)
+	g, err := lookupGuild(sess, gid)
+	if err != nil {
+		return nil, err
+	}
+
+	st := sess.State()
+	st.RLock()
+	roles := append([]*discordgo.Role{}, g.Roles...)
+	st.RUnlock()
+	if len(roles) > 0 {
+		return roles, nil
+	}
+
+	roles, err = sess.GuildRoles(gid)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get roles: %w", err)
+	}
+	for _, r := range roles {
+		st.RoleAdd(gid, r)
+	}
+	return roles, nil
+}
+
+// lookupMember returns a member from state, or gets it with REST and adds it to state
+func lookupMember(sess Transport, gid string, uid string) (*discordgo.Member, error) {
+	sess = guildTransport(sess, gid)
+	m, err := sess.State().Member(gid, uid)
+	if err == nil {
+		return m, nil
+	}
+
+	m, err = sess.GuildMember(gid, uid)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find member: %w", err)
+	}
+	m.GuildID = gid
+	sess.State().MemberAdd(m)
+	return m, nil
+}
+
+// guildMembers returns a copy of every member of a guild
+//	if state has fewer than the guild's member count, or the count isn't known
+//	because the guild came from REST, they're requested from the gateway first,
+//	which needs the GuildMembers intent
+//	without servermembers set it's only the members state already has
+func guildMembers(sess Transport, gid string) ([]*discordgo.Member, error) {
+	sess = guildTransport(sess, gid)
+	g, err := lookupGuild(sess, gid)
+	if err != nil {
+		return nil, err
+	}
+
+	st := sess.State()
+	st.RLock()
+	count := g.MemberCount
+	if count == 0 {
+		count = g.ApproximateMemberCount
+	}
+	missing := count == 0 || len(g.Members) < count
+	st.RUnlock()
+	if missing && Config().ServerMembers {
+		requestMembers(sess, gid)
+	}
+
+	// copied under the lock, state's members are updated by the gateway
+	st.RLock()
+	members := make([]*discordgo.Member, len(g.Members))
+	for i, m := range g.Members {
+		c := *m
+		if c.GuildID == "" {
+			c.GuildID = gid
+		}
+		members[i] = &c
+	}
+	st.RUnlock()
+	return members, nil
+}
+
+// requestMembers gets a guild's members into state
+//	callers while a request is running wait for it instead of sending another,
+//	and a guild isn't requested again for memberRequestInterval after one succeeds
+func requestMembers(sess Transport, gid string) {
+	memberRequestMutex.Lock()
+	req, ok := memberRequests[gid]
+	if ok && (req.at.IsZero() || time.Since(req.at) < memberRequestInterval) {
+		memberRequestMutex.Unlock()
+		<-req.done
+		return
+	}
+	req = &memberRequest{done: make(chan bool)}
+	memberRequests[gid] = req
+	memberRequestMutex.Unlock()
+
+	started := time.Now()
+	err := sess.RequestMembers(gid, memberRequestTimeout)
+
+	memberRequestMutex.Lock()
+	if err == nil {
+		req.at = time.Now()
+	} else {
+		// failed requests can be tried again straight away
+		delete(memberRequests, gid)
+	}
+	memberRequestMutex.Unlock()
+	close(req.done)
+
+	if err != nil {
+		lookupLog.Warn("couldn't get guild members", "guild", gid, "err", err)
+		return
+	}
+	lookupLog.Debug("got guild members", "guild", gid, "took", time.Since(started))
+}
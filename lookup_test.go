@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestGuildMembers(t *testing.T) {
+	tests := []struct {
+		name          string
+		serverMembers bool
+		err           error
+		want          int
+		requests      int
+		// like a guild from REST, which has no member count
+		unknownCount bool
+	}{
+		{"no intent", false, nil, 5, 0, false},
+		{"requested", true, nil, 6, 1, false},
+		{"request failed", true, errors.New("timed out"), 5, 2, false},
+		{"unknown count", true, nil, 6, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestBot(t)
+			setConfig(&configJSON{Prefixes: []string{"!"}, ServerMembers: tt.serverMembers})
+			memberRequestMutex.Lock()
+			memberRequests = make(map[string]*memberRequest)
+			memberRequestMutex.Unlock()
+
+			f.AddOfflineMember(testGuild, &discordgo.User{ID: "105", Username: "offline"})
+			f.MembersErr = tt.err
+			if tt.unknownCount {
+				g, _ := f.State().Guild(testGuild)
+				g.MemberCount = 0
+			}
+
+			// the second lookup only requests again if the first failed
+			for i := 0; i < 2; i++ {
+				members, err := guildMembers(f, testGuild)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(members) != tt.want {
+					t.Errorf("got %d members, want %d", len(members), tt.want)
+				}
+			}
+			if f.MemberRequests != tt.requests {
+				t.Errorf("got %d member requests, want %d", f.MemberRequests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestGuildMembersCopies(t *testing.T) {
+	f := newTestBot(t)
+	members, err := guildMembers(f, testGuild)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range members {
+		m.Nick = "changed"
+	}
+
+	g, _ := f.State().Guild(testGuild)
+	for _, m := range g.Members {
+		if m.Nick != "" {
+			t.Fatalf("state member %s was changed", m.User.Username)
+		}
+	}
+}
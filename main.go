@@ -1,122 +1,218 @@
 package main
 
 import (
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-type configJSON struct {
-	Token          string
-	OwnerID        string
-	Prefixes       []string
-	PrefixOptional bool
-	Status         string
-	SendErrors     bool
-}
-
-// Config JSON
-var Config configJSON
+var mainLog = NewLogger("main")
 
 func main() {
-	fmt.Println("Initializing...")
+	flag.StringVar(&settingsDir, "settings", settingsDirDefault(), "directory with config.json and other settings files")
+	flag.Parse()
+
+	mainLog.Info("initializing")
 
-	confjson, err := ioutil.ReadFile("./settings/config.json")
+	conf, err := loadConfig()
 	if err != nil {
-		fmt.Println("Unable to read config.json")
+		mainLog.Error("couldn't load config", "err", err)
 		return
 	}
+	setConfig(conf)
+	if err := configureLogging(conf.Log); err != nil {
+		mainLog.Error("couldn't set up logging", "err", err)
+	}
 
-	err = json.Unmarshal(confjson, &Config)
+	err = loadAllSettings()
 	if err != nil {
-		fmt.Println("JSON error in config.json", err)
+		mainLog.Error("couldn't load settings", "err", err)
 		return
 	}
 
-	discord, err := discordgo.New("Bot " + Config.Token)
+	sessions, err := openShards(conf.Token, conf.Shards, setupSession)
 	if err != nil {
-		fmt.Println("Error creating Discord session", err)
+		mainLog.Error("error opening discord session", "err", err)
+		if strings.Contains(err.Error(), "4014") {
+			mainLog.Error("disallowed intents, turn on Message Content Intent in the developer portal, and Server Members Intent if servermembers is set")
+		}
 		return
 	}
 
-	discord.AddHandler(ready)
-	discord.AddHandler(messageCreate)
-
-	err = discord.Open()
+	control, err := startControlServer(sessionTransport{sessions[0]}, conf.Control)
 	if err != nil {
-		fmt.Println("Error opening Discord session", err)
-		return
+		mainLog.Error("error starting control server", "err", err)
 	}
 
-	fmt.Println("TussBot initialized")
+	mainLog.Info("TussBot initialized")
 	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
-	<-sc
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, os.Interrupt, os.Kill)
+
+	for {
+		select {
+		case sig := <-sc:
+			if sig != syscall.SIGHUP {
+				mainLog.Info("shutting down")
+				shutdown(sessions, control)
+				return
+			}
+
+			changed, restart, err := reloadConfig(sessions)
+			if err != nil {
+				mainLog.Error("error reloading config.json", "err", err)
+			} else {
+				mainLog.Info("reloaded config.json", "changed", strings.Join(changed, ","), "restart", strings.Join(restart, ","))
+			}
+		case res := <-configReloads:
+			changed, restart, err := reloadConfig(sessions)
+			res <- configReload{changed, restart, err}
+		}
+	}
+}
+
+// settings dir from TUSSBOT_SETTINGS, used if -settings isn't given
+func settingsDirDefault() string {
+	if dir := os.Getenv("TUSSBOT_SETTINGS"); dir != "" {
+		return dir
+	}
+	return settingsDir
+}
+
+// setupSession sets intents and handlers on a shard's session before it connects
+func setupSession(discord *discordgo.Session) {
+	discord.Identify.Intents = discordgo.IntentsAllWithoutPrivileged | discordgo.IntentMessageContent
+	// GuildMembers lets lookup.go request the members of large guilds
+	//	it's privileged, so it's only asked for if servermembers is set
+	//	and Server Members Intent is turned on for the bot
+	if Config().ServerMembers {
+		discord.Identify.Intents |= discordgo.IntentGuildMembers
+	}
 
-	fmt.Println("Shutting down...")
-	discord.Close()
+	discord.AddHandler(ready)
+	discord.AddHandler(messageCreate)
+	discord.AddHandler(interactionCreate)
+	discord.AddHandler(guildCreate)
+
+	// keep roleCache and userCache correct
+	discord.AddHandler(guildRoleCreate)
+	discord.AddHandler(guildRoleUpdate)
+	discord.AddHandler(guildRoleDelete)
+	discord.AddHandler(guildMemberUpdate)
+	discord.AddHandler(userUpdate)
 }
 
 func ready(sess *discordgo.Session, event *discordgo.Ready) {
-	if Config.Status != "" {
-		sess.UpdateStatus(0, Config.Status)
+	conf := Config()
+	if conf.Status != "" {
+		sess.UpdateGameStatus(0, conf.Status)
+	}
+	// slash commands are global, so only one shard registers them
+	if conf.SlashCommands && sess.ShardID == 0 {
+		registerSlashCommands(sess)
 	}
 }
 
+// guilds are created on startup and when the bot joins them
+func guildCreate(sess *discordgo.Session, g *discordgo.GuildCreate) {
+	restoreQueue(sessionTransport{sess}, g.ID)
+}
+
 func messageCreate(sess *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.Author.ID == sess.State.User.ID {
+	if m.Author.ID == sess.State.User.ID || isShuttingDown() {
 		return
 	}
 
 	// "go" is unnecessary here as lib already calls "go messageCreate..."
-	HandleCommand(sess, m.Message)
+	HandleCommand(sessionTransport{sess}, m.Message)
+}
+
+// runtimeStats is what the stats command shows
+type runtimeStats struct {
+	AllocMB    float64
+	StackMB    float64
+	PauseMS    float64
+	Goroutines int
+	Guilds     int
+	Shards     []shardStatus
+	RoleCache  cacheStats
+	UserCache  cacheStats
+}
+
+func getRuntimeStats(sess Transport) runtimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	guilds := 0
+	eachShard(sess, func(t Transport) {
+		t.State().RLock()
+		guilds += len(t.State().Guilds)
+		t.State().RUnlock()
+	})
+
+	return runtimeStats{
+		AllocMB:    float64(m.Alloc) / 1024 / 1024,
+		StackMB:    float64(m.StackSys) / 1024 / 1024,
+		PauseMS:    float64(m.PauseNs[(m.NumGC+255)%256] / 1000000),
+		Goroutines: runtime.NumGoroutine(),
+		Guilds:     guilds,
+		Shards:     shardStatuses(),
+		RoleCache:  roleCache.stats(),
+		UserCache:  userCache.stats(),
+	}
+}
+
+// setStatus sets the bot's status on every shard and saves it to config.json
+func setStatus(sess Transport, status string) error {
+	eachShard(sess, func(t Transport) {
+		t.UpdateGameStatus(0, status)
+	})
+	return updateConfig(func(c *configJSON) {
+		c.Status = status
+	})
 }
 
 func init() {
 	RegisterCommand(Command{
-		aliases:   []string{"stats"},
-		help:      "bot runtime stats",
-		emptyArg:  true,
-		ownerOnly: true,
+		aliases:  []string{"stats"},
+		help:     "bot runtime stats",
+		emptyArg: true,
+		tier:     TierOwner,
 		callback: func(ca CommandArgs) bool {
-			var m runtime.MemStats
-			runtime.ReadMemStats(&m)
-			mbAlloc := float64(m.Alloc) / 1024 / 1024
-			mbStack := float64(m.StackSys) / 1024 / 1024
-			pauseTime := float64(m.PauseNs[(m.NumGC+255)%256] / 1000000)
-			numRoutines := runtime.NumGoroutine()
-			stats := fmt.Sprintf("`alloc: %.2fMB`\n`stack: %.2fMB`\n`pause: %.2fms`\n`numgo: %d`", mbAlloc, mbStack, pauseTime, numRoutines)
-			QuickEmbed(ca, QEmbed{title: "runtime stats", content: stats})
+			rs := getRuntimeStats(ca.sess)
+			stats := ca.T("stats.runtime", "alloc", fmt.Sprintf("%.2f", rs.AllocMB), "stack", fmt.Sprintf("%.2f", rs.StackMB),
+				"pause", fmt.Sprintf("%.2f", rs.PauseMS), "goroutines", rs.Goroutines, "guilds", rs.Guilds)
+			stats += "\n" + ca.T("stats.cache", "cache", ca.T("stats.roles"), "entries", rs.RoleCache.Entries, "hits", rs.RoleCache.Hits, "misses", rs.RoleCache.Misses)
+			stats += "\n" + ca.T("stats.cache", "cache", ca.T("stats.users"), "entries", rs.UserCache.Entries, "hits", rs.UserCache.Hits, "misses", rs.UserCache.Misses)
+			if len(rs.Shards) > 1 {
+				stats += "\n" + ca.T("stats.thisshard", "shard", shardID(ca.sess))
+				for _, sh := range rs.Shards {
+					if !sh.Connected {
+						stats += "\n" + ca.T("stats.shard.down", "shard", sh.ID, "guilds", sh.Guilds)
+						continue
+					}
+					stats += "\n" + ca.T("stats.shard", "shard", sh.ID, "guilds", sh.Guilds, "latency", fmt.Sprintf("%.0f", sh.LatencyMS))
+				}
+			}
+			QuickEmbed(ca, QEmbed{title: ca.T("stats.title"), content: stats})
 			return false
 		}})
 
 	RegisterCommand(Command{
-		aliases:   []string{"setstatus", "status"},
-		help:      "set bot status",
-		ownerOnly: true,
+		aliases: []string{"setstatus", "status"},
+		help:    "set bot status",
+		params:  []Param{{name: "status", kind: ParamRest}},
+		tier:    TierOwner,
 		callback: func(ca CommandArgs) bool {
 
-			status := ca.args
-			ca.sess.UpdateStatus(0, status)
-
-			Config.Status = status
-
-			// TO DO: helper func for writing bytes to JSON
-			b, err := json.MarshalIndent(Config, "", "\t")
-			if err != nil {
-				fmt.Println("Error marshaling JSON for config.json", err)
-				return false
-			}
-			err = ioutil.WriteFile("./settings/config.json", b, 0644)
+			err := setStatus(ca.sess, ca.Str("status"))
 			if err != nil {
-				fmt.Println("Error saving config.json", err)
-				return false
+				mainLog.WithCommand(ca).Error("couldn't save status", "err", err)
 			}
 
 			return false
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// users in the test guild
+var (
+	testGM     = &discordgo.User{ID: "100", Username: "gm"}
+	testPlayer = &discordgo.User{ID: "101", Username: "player"}
+	testAdmin  = &discordgo.User{ID: "102", Username: "admin"}
+	testOwner  = &discordgo.User{ID: "103", Username: "owner"}
+	testBotAdm = &discordgo.User{ID: "104", Username: "botadmin"}
+)
+
+// IDs in the test guild
+const (
+	testGuild      = "1"
+	testTable      = "10"
+	testMusic      = "11"
+	testRoleGM     = "20"
+	testRoleAdmin  = "21"
+	testAdminPerms = discordgo.PermissionManageServer
+)
+
+func TestMain(m *testing.M) {
+	configureLogging(logConfig{Level: "error"})
+	os.Exit(m.Run())
+}
+
+// newTestBot returns a FakeTransport with one guild in it, and resets everything global
+//	- the guild has a table channel and a music channel
+//	- testGM has the gm role, testAdmin has manage server, testPlayer has no roles
+//	- testOwner is the owner and testBotAdm a bot admin in config.json, "!" is the only prefix
+func newTestBot(t *testing.T) *FakeTransport {
+	settingsDir = t.TempDir()
+	setConfig(&configJSON{Prefixes: []string{"!"}, OwnerID: testOwner.ID, Admins: []string{testBotAdm.ID}})
+	if err := loadAllSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	guildConfigMutex.Lock()
+	guildConfigs = make(map[string]*guildConfig)
+	guildConfigMutex.Unlock()
+
+	cooldownMutex.Lock()
+	cooldownUses = make(map[string][]time.Time)
+	cooldownWarned = make(map[string]time.Time)
+	cooldownMutex.Unlock()
+
+	listMutex.Lock()
+	sessionList = make(map[string]*musicSession)
+	listMutex.Unlock()
+
+	// button listeners started by the test
+	t.Cleanup(closeAllButtons)
+
+	f := NewFakeTransport("1000", "tussbot")
+	f.AddGuild(testGuild, "test guild", "999")
+	f.AddChannel(testGuild, testTable, "table", discordgo.ChannelTypeGuildText)
+	f.AddChannel(testGuild, testMusic, "music", discordgo.ChannelTypeGuildText)
+	f.AddRole(testGuild, testRoleGM, "gm", 0)
+	f.AddRole(testGuild, testRoleAdmin, "admin", testAdminPerms)
+	f.AddMember(testGuild, testGM, testRoleGM)
+	f.AddMember(testGuild, testPlayer)
+	f.AddMember(testGuild, testAdmin, testRoleAdmin)
+	f.AddMember(testGuild, testOwner)
+	f.AddMember(testGuild, testBotAdm)
+	return f
+}
+
+// lastSent returns the last message sent to a channel, failing the test if there isn't one
+func lastSent(t *testing.T, f *FakeTransport, chid string) *discordgo.Message {
+	t.Helper()
+	sent := f.SentTo(chid)
+	if len(sent) == 0 {
+		t.Fatalf("nothing sent to %s", chid)
+	}
+	return sent[len(sent)-1]
+}
+
+// embedText returns a message's embed description, or its content if it has no embed
+func embedText(m *discordgo.Message) string {
+	if len(m.Embeds) == 0 {
+		return m.Content
+	}
+	return m.Embeds[0].Description
+}
+
+// isError returns whether a message is an error sent with SendError
+func isError(m *discordgo.Message) bool {
+	return len(m.Embeds) > 0 && m.Embeds[0].Author != nil && m.Embeds[0].Author.Name == "error"
+}
+
+// waitFor waits for cond to be true, for things done by button handlers in the background
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
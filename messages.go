@@ -2,14 +2,17 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+var msgLog = NewLogger("messages")
+
 // GetDMChannel finds a user by ID and returns a Channel for DMing them in
-func GetDMChannel(sess *discordgo.Session, id string) (*discordgo.Channel, error) {
+func GetDMChannel(sess Transport, id string) (*discordgo.Channel, error) {
 	ok, user := CacheUser(sess, id)
 	if !ok {
 		return nil, fmt.Errorf("error getting user to DM")
@@ -22,19 +25,24 @@ func GetDMChannel(sess *discordgo.Session, id string) (*discordgo.Channel, error
 }
 
 // replaces special tokens in a string
-//	%P = command prefix
+//	%P = command prefix of guild gid, or global prefix if gid is ""
 //	^ = ` (so raw literals can be used for newlines)
 //	fixes newline characters in string
-func formatTokens(str string) string {
-	str = strings.Replace(str, "%P", Config.Prefixes[0], -1)
+func formatTokens(str string, gid string) string {
+	str = strings.Replace(str, "%P", GuildPrefix(gid), -1)
 	str = strings.Replace(str, "^", "`", -1)
 	str = strings.Replace(str, "\\n", "\n", -1)
 	return str
 }
 
+// isInteraction returns whether replies should go to a slash command interaction
+func (ca CommandArgs) isInteraction() bool {
+	return ca.reply != nil && ca.chO == ""
+}
+
 // SendReply to a message's source channel with a string -- returns message and error
 func SendReply(ca CommandArgs, str string) (*discordgo.Message, error) {
-	str = formatTokens(str)
+	str = formatTokens(str, ca.guildID())
 	str = ClampStr(str, 2000)
 
 	ch := ""
@@ -44,7 +52,13 @@ func SendReply(ca CommandArgs, str string) (*discordgo.Message, error) {
 		ch = ca.msg.ChannelID
 	}
 
-	nm, err := ca.sess.ChannelMessageSend(ch, str)
+	var nm *discordgo.Message
+	var err error
+	if ca.isInteraction() {
+		nm, err = ca.reply.send(&discordgo.MessageSend{Content: str}, ca.ephemeral)
+	} else {
+		nm, err = ca.sess.ChannelMessageSend(ch, str)
+	}
 	if err != nil {
 		err = fmt.Errorf("error sending reply in %s: %w", GetChannelName(ca.sess, ch), err)
 		SendError(ca, err.Error())
@@ -52,21 +66,21 @@ func SendReply(ca CommandArgs, str string) (*discordgo.Message, error) {
 	return nm, err
 }
 
-func limitEmbedLength(em *discordgo.MessageEmbed) *discordgo.MessageEmbed {
-	em.Title = ClampStr(formatTokens(em.Title), 256)
-	em.Description = ClampStr(formatTokens(em.Description), 2048)
+func limitEmbedLength(em *discordgo.MessageEmbed, gid string) *discordgo.MessageEmbed {
+	em.Title = ClampStr(formatTokens(em.Title, gid), 256)
+	em.Description = ClampStr(formatTokens(em.Description, gid), 2048)
 
 	for len(em.Fields) > 25 {
 		em.Fields = em.Fields[:len(em.Fields)-1]
 	}
 
 	for _, field := range em.Fields {
-		field.Name = ClampStr(formatTokens(field.Name), 256)
-		field.Value = ClampStr(formatTokens(field.Value), 1024)
+		field.Name = ClampStr(formatTokens(field.Name, gid), 256)
+		field.Value = ClampStr(formatTokens(field.Value, gid), 1024)
 	}
 
 	if em.Footer != nil {
-		em.Footer.Text = ClampStr(formatTokens(em.Footer.Text), 2048)
+		em.Footer.Text = ClampStr(formatTokens(em.Footer.Text, gid), 2048)
 	}
 
 	if em.Author != nil {
@@ -79,7 +93,7 @@ func limitEmbedLength(em *discordgo.MessageEmbed) *discordgo.MessageEmbed {
 // SendEmbed to a message's source channel with an embed
 //	only title, description, field names & values, and footer text are run through formatTokens
 func SendEmbed(ca CommandArgs, em *discordgo.MessageEmbed) (*discordgo.Message, error) {
-	em = limitEmbedLength(em)
+	em = limitEmbedLength(em, ca.guildID())
 
 	ch := ""
 	if ca.chO != "" {
@@ -88,7 +102,13 @@ func SendEmbed(ca CommandArgs, em *discordgo.MessageEmbed) (*discordgo.Message,
 		ch = ca.msg.ChannelID
 	}
 
-	nm, err := ca.sess.ChannelMessageSendEmbed(ch, em)
+	var nm *discordgo.Message
+	var err error
+	if ca.isInteraction() {
+		nm, err = ca.reply.send(&discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{em}}, ca.ephemeral)
+	} else {
+		nm, err = ca.sess.ChannelMessageSendEmbed(ch, em)
+	}
 	if err != nil {
 		err = fmt.Errorf("error sending embed in %s: %w", GetChannelName(ca.sess, ch), err)
 		SendError(ca, err.Error())
@@ -96,6 +116,29 @@ func SendEmbed(ca CommandArgs, em *discordgo.MessageEmbed) (*discordgo.Message,
 	return nm, err
 }
 
+// SendFile to a message's source channel
+func SendFile(ca CommandArgs, name string, r io.Reader) (*discordgo.Message, error) {
+	ch := ""
+	if ca.chO != "" {
+		ch = ca.chO
+	} else {
+		ch = ca.msg.ChannelID
+	}
+
+	var nm *discordgo.Message
+	var err error
+	if ca.isInteraction() {
+		nm, err = ca.reply.send(&discordgo.MessageSend{Files: []*discordgo.File{{Name: name, Reader: r}}}, ca.ephemeral)
+	} else {
+		nm, err = ca.sess.ChannelFileSend(ch, name, r)
+	}
+	if err != nil {
+		err = fmt.Errorf("error sending file in %s: %w", GetChannelName(ca.sess, ch), err)
+		SendError(ca, err.Error())
+	}
+	return nm, err
+}
+
 // EditMessage edits a message while adhereing to string lengths
 func EditMessage(ca CommandArgs, me *discordgo.MessageEdit) error {
 	if me.Content != nil {
@@ -105,7 +148,7 @@ func EditMessage(ca CommandArgs, me *discordgo.MessageEdit) error {
 	}
 
 	if me.Embed != nil {
-		me.Embed = limitEmbedLength(me.Embed)
+		me.Embed = limitEmbedLength(me.Embed, ca.guildID())
 	}
 
 	_, err := ca.sess.ChannelMessageEditComplex(me)
@@ -139,7 +182,10 @@ func QuickEmbed(ca CommandArgs, qem QEmbed) (*discordgo.Message, error) {
 
 // SendError to a message's source channel in a premade error embed
 func SendError(ca CommandArgs, str string) *discordgo.Message {
-	str = formatTokens(str)
+	str = formatTokens(str, ca.guildID())
+	if ca.cmd != nil {
+		commandUserErrs.Inc(ca.cmd.path)
+	}
 
 	// not using SendEmbed here so we don't get stuck in a SendError loop
 	ch := ""
@@ -166,20 +212,30 @@ func SendError(ca CommandArgs, str string) *discordgo.Message {
 		icon = user.AvatarURL("")
 	}
 
-	msg, err := ca.sess.ChannelMessageSendEmbed(ch, &discordgo.MessageEmbed{Description: ClampStr(str, 2000), Color: 0xff0000,
-		Footer: &discordgo.MessageEmbedFooter{Text: ca.content}, Author: &discordgo.MessageEmbedAuthor{Name: "error", IconURL: icon}})
+	em := &discordgo.MessageEmbed{Description: ClampStr(str, 2000), Color: 0xff0000,
+		Footer: &discordgo.MessageEmbedFooter{Text: ca.content}, Author: &discordgo.MessageEmbedAuthor{Name: "error", IconURL: icon}}
+
+	var msg *discordgo.Message
+	var err error
+	if ca.isInteraction() {
+		// errors are only shown to whoever used the slash command
+		msg, err = ca.reply.send(&discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{em}}, true)
+	} else {
+		msg, err = ca.sess.ChannelMessageSendEmbed(ch, em)
+	}
 	if err != nil {
-		err = fmt.Errorf("error sending error in %s: %w", GetChannelName(ca.sess, ch), err)
-		fmt.Println(err)
+		msgLog.Error("error sending error", "channel", GetChannelName(ca.sess, ch), "err", err)
 		return nil
 	}
 	return msg
 }
 
 // SendErrorTemp sends an error and then deletes it after some timeout
+//	errors for slash commands are ephemeral followups that only the user sees
+//	and can dismiss, so those are left alone
 func SendErrorTemp(ca CommandArgs, str string, timeout int) {
 	msg := SendError(ca, str)
-	if msg != nil {
+	if msg != nil && !ca.isInteraction() {
 		go func() {
 			time.Sleep(time.Duration(timeout) * time.Second)
 			ca.sess.ChannelMessageDelete(msg.ChannelID, msg.ID)
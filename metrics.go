@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// metricCounter is a counter with one label, zero or more label values
+type metricCounter struct {
+	sync.Mutex
+	name   string
+	help   string
+	label  string
+	values map[string]float64
+}
+
+func newCounter(name string, help string, label string) *metricCounter {
+	return &metricCounter{name: name, help: help, label: label, values: make(map[string]float64)}
+}
+
+// Inc adds one to the counter for a label value
+//	use "" if the counter has no label
+func (c *metricCounter) Inc(lv string) {
+	c.Lock()
+	c.values[lv]++
+	c.Unlock()
+}
+
+func (c *metricCounter) write(w io.Writer) {
+	c.Lock()
+	defer c.Unlock()
+
+	writeMetricHeader(w, c.name, c.help, "counter")
+	if len(c.values) == 0 && c.label == "" {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+	}
+	for _, lv := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, metricLabels(c.label, lv), formatMetric(c.values[lv]))
+	}
+}
+
+// metricHistogram counts observations into buckets, in seconds
+type metricHistogram struct {
+	sync.Mutex
+	name    string
+	help    string
+	label   string
+	buckets []float64
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// command and ytdl latency in seconds, ytdl takes a few
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+func newHistogram(name string, help string, label string, buckets []float64) *metricHistogram {
+	return &metricHistogram{name: name, help: help, label: label, buckets: buckets, series: make(map[string]*histogramSeries)}
+}
+
+// Observe records a duration for a label value
+func (h *metricHistogram) Observe(lv string, d time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+
+	s, ok := h.series[lv]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[lv] = s
+	}
+	v := d.Seconds()
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *metricHistogram) write(w io.Writer) {
+	h.Lock()
+	defer h.Unlock()
+
+	writeMetricHeader(w, h.name, h.help, "histogram")
+	lvs := make([]string, 0, len(h.series))
+	for lv := range h.series {
+		lvs = append(lvs, lv)
+	}
+	sort.Strings(lvs)
+
+	for _, lv := range lvs {
+		s := h.series[lv]
+		prefix := ""
+		if h.label != "" {
+			prefix = fmt.Sprintf("%s=%q,", h.label, lv)
+		}
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket{%sle=\"%s\"} %d\n", h.name, prefix, formatMetric(b), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", h.name, prefix, s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, metricLabels(h.label, lv), formatMetric(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, metricLabels(h.label, lv), s.count)
+	}
+}
+
+// metricGauge is read when metrics are scraped
+//	value returns label values to readings, use "" if the gauge has no label
+type metricGauge struct {
+	name  string
+	help  string
+	label string
+	value func() map[string]float64
+}
+
+func (g *metricGauge) write(w io.Writer) {
+	writeMetricHeader(w, g.name, g.help, "gauge")
+	values := g.value()
+	for _, lv := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, metricLabels(g.label, lv), formatMetric(values[lv]))
+	}
+}
+
+func writeMetricHeader(w io.Writer, name string, help string, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func metricLabels(label string, lv string) string {
+	if label == "" {
+		return ""
+	}
+	return fmt.Sprintf("{%s=%q}", label, lv)
+}
+
+func formatMetric(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// single value gauge
+func gaugeValue(v float64) map[string]float64 {
+	return map[string]float64{"": v}
+}
+
+var (
+	commandsExecuted = newCounter("tussbot_commands_executed_total", "Commands run, by command path.", "command")
+	commandsFailed   = newCounter("tussbot_commands_failed_total", "Commands that panicked, by command path.", "command")
+	commandUserErrs  = newCounter("tussbot_command_user_errors_total", "Errors sent back to users, like bad arguments and cooldowns, by command path.", "command")
+	commandLatency   = newHistogram("tussbot_command_duration_seconds", "Time spent in command callbacks, by command path.", "command", latencyBuckets)
+
+	voiceSendTimeouts = newCounter("tussbot_voice_send_timeouts_total", "Opus frames that couldn't be sent to discord within a second.", "")
+	ytdlLatency       = newHistogram("tussbot_ytdl_duration_seconds", "Time youtube-dl took to resolve a URL.", "", latencyBuckets)
+)
+
+var metricGauges = []*metricGauge{
+	{name: "tussbot_music_sessions", help: "Music sessions, by whether they're playing.", label: "state", value: func() map[string]float64 {
+		values := map[string]float64{"playing": 0, "idle": 0}
+		for _, ms := range musicSessions() {
+			if ms.info().Playing {
+				values["playing"]++
+			} else {
+				values["idle"]++
+			}
+		}
+		return values
+	}},
+	{name: "tussbot_ffmpeg_processes", help: "Running ffmpeg processes.", value: func() map[string]float64 {
+		return gaugeValue(float64(countFFMPEG()))
+	}},
+	{name: "tussbot_ffmpeg_frame_buffer_fill", help: "Fraction of the ffmpeg frame buffer in use, by guild.", label: "guild", value: func() map[string]float64 {
+		listMutex.Lock()
+		defer listMutex.Unlock()
+
+		values := make(map[string]float64)
+		for gid, ms := range sessionList {
+			if n, size := ms.ffmpeg.bufferFill(); size > 0 {
+				values[gid] = float64(n) / float64(size)
+			}
+		}
+		return values
+	}},
+}
+
+// runtime numbers from the stats command, need a session for the guild count
+func runtimeGauges(sess Transport) []*metricGauge {
+	rs := getRuntimeStats(sess)
+	return []*metricGauge{
+		{name: "tussbot_alloc_bytes", help: "Bytes of allocated heap objects.", value: func() map[string]float64 { return gaugeValue(rs.AllocMB * 1024 * 1024) }},
+		{name: "tussbot_stack_bytes", help: "Bytes of stack memory from the OS.", value: func() map[string]float64 { return gaugeValue(rs.StackMB * 1024 * 1024) }},
+		{name: "tussbot_gc_pause_seconds", help: "Length of the last GC pause.", value: func() map[string]float64 { return gaugeValue(rs.PauseMS / 1000) }},
+		{name: "tussbot_goroutines", help: "Running goroutines.", value: func() map[string]float64 { return gaugeValue(float64(rs.Goroutines)) }},
+		{name: "tussbot_guilds", help: "Guilds the bot is in.", value: func() map[string]float64 { return gaugeValue(float64(rs.Guilds)) }},
+		{name: "tussbot_shard_guilds", help: "Guilds on each shard.", label: "shard", value: func() map[string]float64 {
+			return shardValues(rs.Shards, func(sh shardStatus) float64 { return float64(sh.Guilds) })
+		}},
+		{name: "tussbot_shard_connected", help: "Whether each shard is connected to the gateway.", label: "shard", value: func() map[string]float64 {
+			return shardValues(rs.Shards, func(sh shardStatus) float64 {
+				if sh.Connected {
+					return 1
+				}
+				return 0
+			})
+		}},
+		{name: "tussbot_shard_latency_seconds", help: "Heartbeat latency of each shard.", label: "shard", value: func() map[string]float64 {
+			return shardValues(rs.Shards, func(sh shardStatus) float64 { return sh.LatencyMS / 1000 })
+		}},
+	}
+}
+
+func shardValues(shards []shardStatus, fn func(shardStatus) float64) map[string]float64 {
+	values := make(map[string]float64)
+	for _, sh := range shards {
+		values[strconv.Itoa(sh.ID)] = fn(sh)
+	}
+	return values
+}
+
+// writeMetrics writes every metric in the prometheus text format
+func writeMetrics(w io.Writer, sess Transport) {
+	commandsExecuted.write(w)
+	commandsFailed.write(w)
+	commandUserErrs.write(w)
+	commandLatency.write(w)
+	voiceSendTimeouts.write(w)
+	ytdlLatency.write(w)
+	for _, g := range metricGauges {
+		g.write(w)
+	}
+	for _, g := range runtimeGauges(sess) {
+		g.write(w)
+	}
+}
+
+// counts commands and times their callbacks
+//	regex calls are only counted if they consumed the message
+var metricsMiddleware = Middleware{
+	name: "metrics",
+	after: func(ca CommandArgs, cmd *Command, consumed bool) {
+		if ca.isRegex && !consumed {
+			return
+		}
+		commandsExecuted.Inc(cmd.path)
+		commandLatency.Observe(cmd.path, time.Since(ca.started))
+	},
+	onError: func(ca CommandArgs, cmd *Command, r interface{}, stack []byte) {
+		commandsExecuted.Inc(cmd.path)
+		commandsFailed.Inc(cmd.path)
+		commandLatency.Observe(cmd.path, time.Since(ca.started))
+	},
+}
+
+func init() {
+	UseMiddleware(metricsMiddleware)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// Middleware hooks into command dispatch around a command's callback
+//	- before runs in order before the callback, returning false stops
+//		the command and anything after it from handling the message
+//	- after runs in reverse order with what the callback returned
+//	- onReject runs in reverse order if a before stopped the command,
+//		for cleanup that should happen whether or not the callback ran
+//	- onError runs in reverse order if the callback panics,
+//		with the recovered value and the stack at the time
+type Middleware struct {
+	name     string
+	before   func(CommandArgs, *Command) bool
+	after    func(CommandArgs, *Command, bool)
+	onReject func(CommandArgs, *Command)
+	onError  func(CommandArgs, *Command, interface{}, []byte)
+}
+
+var middlewareChain []Middleware
+
+// UseMiddleware adds middleware that runs for every command
+//	middleware that only some commands need goes in Command.middleware instead
+func UseMiddleware(mw Middleware) {
+	middlewareChain = append(middlewareChain, mw)
+}
+
+// runCallback runs a command's callback through the global and the command's own middleware
+//	returns whether the message was consumed
+func runCallback(ca CommandArgs, cmd *Command) (consumed bool) {
+	chain := append(append([]Middleware{}, middlewareChain...), cmd.middleware...)
+	ca.started = time.Now()
+
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			for i := len(chain) - 1; i >= 0; i-- {
+				if chain[i].onError != nil {
+					chain[i].onError(ca, cmd, r, stack)
+				}
+			}
+			consumed = true
+		}
+	}()
+
+	for _, mw := range chain {
+		if mw.before != nil && !mw.before(ca, cmd) {
+			for i := len(chain) - 1; i >= 0; i-- {
+				if chain[i].onReject != nil {
+					chain[i].onReject(ca, cmd)
+				}
+			}
+			return true
+		}
+	}
+
+	consumed = cmd.callback(ca)
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].after != nil {
+			chain[i].after(ca, cmd, consumed)
+		}
+	}
+	return consumed
+}
+
+// logs every command that runs
+var logMiddleware = Middleware{
+	name: "log",
+	before: func(ca CommandArgs, cmd *Command) bool {
+		if ca.isRegex {
+			return true
+		}
+		cmdLog.WithCommand(ca).Info("command used", "username", ca.msg.Author.Username, "channelname", GetChannelName(ca.sess, ca.msg.ChannelID))
+		return true
+	},
+}
+
+// enforces Command.cooldowns
+var cooldownMiddleware = Middleware{
+	name: "cooldown",
+	before: func(ca CommandArgs, cmd *Command) bool {
+		return checkCooldowns(ca, cmd)
+	},
+}
+
+// reports panics in callbacks to the owners
+var panicMiddleware = Middleware{
+	name: "panic",
+	onError: func(ca CommandArgs, cmd *Command, r interface{}, stack []byte) {
+		reportPanic(ca, r, stack)
+	},
+}
+
+// deleteInvokingMiddleware deletes the message that called a command once it's consumed
+//	used for music commands so the music channel only has the embed in it
+//	- also deletes it if the command was stopped, ie by a cooldown
+var deleteInvokingMiddleware = Middleware{
+	name: "delete invoking",
+	after: func(ca CommandArgs, cmd *Command, consumed bool) {
+		if consumed {
+			deleteInvoking(ca)
+		}
+	},
+	onReject: func(ca CommandArgs, cmd *Command) {
+		deleteInvoking(ca)
+	},
+}
+
+// deletes the message that called a command, if there is one
+func deleteInvoking(ca CommandArgs) {
+	if ca.msg != nil && ca.msg.ID != "" {
+		ca.sess.ChannelMessageDelete(ca.msg.ChannelID, ca.msg.ID)
+	}
+}
+
+func init() {
+	UseMiddleware(panicMiddleware)
+	UseMiddleware(logMiddleware)
+	UseMiddleware(cooldownMiddleware)
+}
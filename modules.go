@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// modules commands can be grouped into, see Command.module
+//	core can't be disabled so the modules command always works
+var moduleNames = []string{"core", "music", "rpg-roll", "rpg-clocks"}
+
+var coreModule = "core"
+
+func isModule(name string) bool {
+	for _, m := range moduleNames {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ModuleEnabled returns whether a module's commands work in a channel
+//	a channel's setting overrides the guild's, modules are enabled by default
+func ModuleEnabled(gid string, chid string, module string) bool {
+	if module == coreModule || gid == "" {
+		return true
+	}
+
+	guildConfigMutex.Lock()
+	defer guildConfigMutex.Unlock()
+
+	gc, ok := guildConfigs[gid]
+	if !ok {
+		return true
+	}
+	if on, ok := gc.ChannelModules[chid][module]; ok {
+		return on
+	}
+	if on, ok := gc.Modules[module]; ok {
+		return on
+	}
+	return true
+}
+
+// commandEnabled returns whether a command's module is enabled where it was called
+func commandEnabled(ca CommandArgs, cmd Command) bool {
+	if ca.msg == nil {
+		return true
+	}
+	return ModuleEnabled(ca.msg.GuildID, ca.msg.ChannelID, cmd.module)
+}
+
+// sets a module on or off for a guild, or a channel if chid isn't empty
+func setModule(gid string, chid string, module string, on bool) {
+	updateGuildConfig(gid, func(gc *guildConfig) {
+		if chid == "" {
+			if gc.Modules == nil {
+				gc.Modules = make(map[string]bool)
+			}
+			gc.Modules[module] = on
+			return
+		}
+
+		if gc.ChannelModules == nil {
+			gc.ChannelModules = make(map[string]map[string]bool)
+		}
+		if gc.ChannelModules[chid] == nil {
+			gc.ChannelModules[chid] = make(map[string]bool)
+		}
+		gc.ChannelModules[chid][module] = on
+	})
+}
+
+// removes a guild's or channel's setting for a module
+func resetModule(gid string, chid string, module string) {
+	updateGuildConfig(gid, func(gc *guildConfig) {
+		if chid == "" {
+			delete(gc.Modules, module)
+			return
+		}
+
+		delete(gc.ChannelModules[chid], module)
+		if len(gc.ChannelModules[chid]) == 0 {
+			delete(gc.ChannelModules, chid)
+		}
+	})
+}
+
+// module name and optional channel for the modules subcommands
+//	returns the channel ID, empty for the whole guild
+func moduleArgs(ca CommandArgs) (string, string, bool) {
+	module := strings.ToLower(ca.Str("module"))
+
+	chid := ""
+	if ch := ca.Channel("channel"); ch != nil {
+		if ch.GuildID != ca.msg.GuildID {
+			SendError(ca, ca.T("modules.otherguild"))
+			return "", "", false
+		}
+		chid = ch.ID
+	}
+	return module, chid, true
+}
+
+// describes where a change applies
+func moduleWhere(ca CommandArgs, chid string) string {
+	if chid == "" {
+		return ca.T("modules.server")
+	}
+	return fmt.Sprintf("<#%s>", chid)
+}
+
+func init() {
+	var modules []string
+	for _, m := range moduleNames {
+		if m != coreModule {
+			modules = append(modules, m)
+		}
+	}
+	moduleParams := []Param{
+		{name: "module", kind: ParamEnum, choices: modules},
+		{name: "channel", kind: ParamChannel, optional: true, help: "only change it in this channel"},
+	}
+
+	RegisterCommand(Command{
+		aliases: []string{"modules", "module"},
+		help: `show or change which commands work in this server\n
+		^%Pmodules disable music^
+		^%Pmodules enable music #music^`,
+		emptyArg: true,
+		noDM:     true,
+		tier:     TierGuildAdmin,
+		callback: func(ca CommandArgs) bool {
+			var lines []string
+			for _, m := range moduleNames {
+				guild := ModuleEnabled(ca.msg.GuildID, "", m)
+				here := ModuleEnabled(ca.msg.GuildID, ca.msg.ChannelID, m)
+
+				status := ca.T("modules.on")
+				if !guild {
+					status = ca.T("modules.off")
+				}
+				if here != guild {
+					if here {
+						status = ca.T("modules.onhere", "status", status)
+					} else {
+						status = ca.T("modules.offhere", "status", status)
+					}
+				}
+				lines = append(lines, fmt.Sprintf("`%s` - %s", m, status))
+			}
+			QuickEmbed(ca, QEmbed{title: ca.T("modules.title"), content: strings.Join(lines, "\n")})
+			return false
+		},
+		subcommands: []Command{
+			{
+				aliases: []string{"enable", "on"},
+				help: `turn a module on in this server or a channel\n
+				^%Pmodules enable music #music^`,
+				params: moduleParams,
+				callback: func(ca CommandArgs) bool {
+					module, chid, ok := moduleArgs(ca)
+					if !ok {
+						return false
+					}
+					setModule(ca.msg.GuildID, chid, module, true)
+					QuickEmbed(ca, QEmbed{content: ca.T("modules.enabled", "module", module, "where", moduleWhere(ca, chid))})
+					return false
+				}},
+			{
+				aliases: []string{"disable", "off"},
+				help: `turn a module off in this server or a channel\n
+				^%Pmodules disable rpg-clocks^`,
+				params: moduleParams,
+				callback: func(ca CommandArgs) bool {
+					module, chid, ok := moduleArgs(ca)
+					if !ok {
+						return false
+					}
+					setModule(ca.msg.GuildID, chid, module, false)
+					QuickEmbed(ca, QEmbed{content: ca.T("modules.disabled", "module", module, "where", moduleWhere(ca, chid))})
+					return false
+				}},
+			{
+				aliases: []string{"reset"},
+				help: `go back to the default for a module\n
+				a channel goes back to the server's setting`,
+				params: moduleParams,
+				callback: func(ca CommandArgs) bool {
+					module, chid, ok := moduleArgs(ca)
+					if !ok {
+						return false
+					}
+					resetModule(ca.msg.GuildID, chid, module)
+					QuickEmbed(ca, QEmbed{content: ca.T("modules.reset", "module", module, "where", moduleWhere(ca, chid))})
+					return false
+				}},
+		}})
+}
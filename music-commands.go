@@ -4,10 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"regexp"
-	"strconv"
 	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -19,32 +18,31 @@ var allowedLinks = []string{`^https:\/\/(?:www\.|m\.)?youtube\.com\/watch\?v=.+`
 	`^https:\/\/(?:www\.)?soundcloud\.com\/.+\/.+`,
 	`^https:\/\/.+\.bandcamp\.com\/track\/.+`}
 
-func getVoiceChannel(sess *discordgo.Session, ch string, uid string) (*discordgo.Channel, *discordgo.VoiceState, error) {
-	tc, err := sess.State.Channel(ch)
+var errNotInVoice = errors.New("user not in a visible voice channel")
+
+func getVoiceChannel(sess Transport, ch string, uid string) (*discordgo.Channel, *discordgo.VoiceState, error) {
+	tc, err := lookupChannel(sess, ch)
 	if err != nil {
 		return nil, nil, fmt.Errorf("couldn't find text channel: %w", err)
 	}
 
-	g, err := sess.State.Guild(tc.GuildID)
+	// voice states only come from the gateway, so there's no REST fallback for them
+	vs, err := sess.State().VoiceState(tc.GuildID, uid)
 	if err != nil {
-		return nil, nil, fmt.Errorf("couldn't find guild: %w", err)
-	}
-
-	for _, vs := range g.VoiceStates {
-		if vs.UserID == uid {
-			vch, err := sess.State.Channel(vs.ChannelID)
-			if err != nil {
-				return nil, nil, fmt.Errorf("couldn't find voice channel: %w", err)
-			}
-
-			return vch, vs, nil
+		if _, gerr := lookupGuild(sess, tc.GuildID); gerr != nil {
+			return nil, nil, gerr
 		}
+		return nil, nil, errNotInVoice
 	}
 
-	return nil, nil, errors.New("user not in a visible voice channel")
+	vch, err := lookupChannel(sess, vs.ChannelID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't find voice channel: %w", err)
+	}
+	return vch, vs, nil
 }
 
-func joinVoiceChannel(sess *discordgo.Session, vs *discordgo.VoiceState) (*discordgo.VoiceConnection, error) {
+func joinVoiceChannel(sess Transport, vs *discordgo.VoiceState) (*discordgo.VoiceConnection, error) {
 	vc, err := sess.ChannelVoiceJoin(vs.GuildID, vs.ChannelID, false, true)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't join voice channel: %w", err)
@@ -55,22 +53,47 @@ func joinVoiceChannel(sess *discordgo.Session, vs *discordgo.VoiceState) (*disco
 var listMutex sync.Mutex
 var sessionList map[string]*musicSession
 
+// findGuildSession returns a guild's music session if it has one
+func findGuildSession(gid string) *musicSession {
+	listMutex.Lock()
+	defer listMutex.Unlock()
+	return sessionList[gid]
+}
+
+// musicSessions returns every guild's music session
+func musicSessions() []*musicSession {
+	listMutex.Lock()
+	defer listMutex.Unlock()
+
+	sessions := make([]*musicSession, 0, len(sessionList))
+	for _, ms := range sessionList {
+		sessions = append(sessions, ms)
+	}
+	return sessions
+}
+
 func getGuildSession(ca CommandArgs) *musicSession {
+	return guildSession(ca.sess, ca.msg.GuildID)
+}
+
+// guildSession returns a guild's music session, creating it if needed
+func guildSession(sess Transport, gid string) *musicSession {
 	listMutex.Lock()
 	defer listMutex.Unlock()
 
-	gid := ca.msg.GuildID
 	ms, ok := sessionList[gid]
 	if !ok {
+		// voice has to go through the shard that owns the guild
+		sess = guildTransport(sess, gid)
 		sessionList[gid] = &musicSession{}
-		sessionList[gid].ffmpeg = &FFMPEGSession{}
+		sessionList[gid].ffmpeg = &FFMPEGSession{log: NewLogger("ffmpeg").With("guild", gid), sess: sess, guild: gid}
 		sessionList[gid].guild = gid
-		sessionList[gid].sess = ca.sess
-		chid, ok := settingsCache.MusicChannels[gid]
+		sessionList[gid].sess = sess
+		chid, ok := guildMusicChannel(gid)
 		if ok {
 			sessionList[gid].musicChan = chid
 		}
-		emid, ok := settingsCache.MusicEmbeds[gid]
+		emid, ok := guildMusicEmbed(gid)
 		if ok {
 			sessionList[gid].embedID = emid
 			sessionList[gid].initEmbed()
@@ -82,39 +105,71 @@ func getGuildSession(ca CommandArgs) *musicSession {
 	return ms
 }
 
-type musicSettings struct {
-	MusicChannels map[string]string
-	MusicEmbeds   map[string]string
-}
+// music settings per guild
+//	"channel" is the music channel ID, "embed" is the embed message ID
+var musicStorage = NewStorage("music", ScopeGuild, musicFile)
 
-var settingsCache musicSettings
+// version 2 moved music.json onto Storage
+var musicFile = &settingsFile{name: "music.json", version: 2, migrations: map[int]func(json.RawMessage) (json.RawMessage, error){
+	1: func(data json.RawMessage) (json.RawMessage, error) {
+		var old struct {
+			MusicChannels map[string]string
+			MusicEmbeds   map[string]string
+		}
+		err := json.Unmarshal(data, &old)
+		if err != nil {
+			return nil, err
+		}
+		guilds := make(map[string]map[string]string)
+		for key, ids := range map[string]map[string]string{"channel": old.MusicChannels, "embed": old.MusicEmbeds} {
+			for gid, id := range ids {
+				if guilds[gid] == nil {
+					guilds[gid] = make(map[string]string)
+				}
+				guilds[gid][key] = id
+			}
+		}
+		return json.Marshal(map[StorageScope]interface{}{ScopeGuild: guilds})
+	},
+}}
 
-func saveMusicSettings() {
-	b, err := json.Marshal(settingsCache)
+// SetGuildMusicEmbed sets the guild's music embed ID and saves it
+func SetGuildMusicEmbed(gid string, mid string) {
+	err := musicStorage.Set(gid, "embed", mid)
 	if err != nil {
-		fmt.Println("Error marshaling JSON for music.json", err)
-		return
+		settingsLog.Error("couldn't save music embed", "guild", gid, "err", err)
 	}
-	err = ioutil.WriteFile("./settings/music.json", b, 0644)
+}
+
+func setGuildMusicChannel(gid string, cid string) {
+	err := musicStorage.Set(gid, "channel", cid)
 	if err != nil {
-		fmt.Println("Error saving music.json", err)
-		return
+		settingsLog.Error("couldn't save music channel", "guild", gid, "err", err)
 	}
 }
 
-// SetGuildMusicEmbed sets the guild's music embed ID and saves it
-func SetGuildMusicEmbed(gid string, mid string) {
-	settingsCache.MusicEmbeds[gid] = mid
-	saveMusicSettings()
+// guildMusicChannel returns the guild's music channel ID, if it has one
+func guildMusicChannel(gid string) (string, bool) {
+	var chid string
+	ok, err := musicStorage.Get(gid, "channel", &chid)
+	if err != nil {
+		settingsLog.Error("couldn't get music channel", "guild", gid, "err", err)
+	}
+	return chid, ok
 }
 
-func setGuildMusicChannel(gid string, cid string) {
-	settingsCache.MusicChannels[gid] = cid
-	saveMusicSettings()
+// guildMusicEmbed returns the ID of the guild's music embed, if it has one
+func guildMusicEmbed(gid string) (string, bool) {
+	var emid string
+	ok, err := musicStorage.Get(gid, "embed", &emid)
+	if err != nil {
+		settingsLog.Error("couldn't get music embed", "guild", gid, "err", err)
+	}
+	return emid, ok
 }
 
 func isMusicChannel(ca CommandArgs) bool {
-	chid, ok := settingsCache.MusicChannels[ca.msg.GuildID]
+	chid, ok := guildMusicChannel(ca.msg.GuildID)
 	if !ok {
 		return false
 	}
@@ -124,12 +179,15 @@ func isMusicChannel(ca CommandArgs) bool {
 	return true
 }
 
-func getVoiceState(ms *musicSession, sess *discordgo.Session, ch string, uid string) (*discordgo.VoiceState, *discordgo.Channel, bool) {
+func getVoiceState(ms *musicSession, sess Transport, ch string, uid string) (*discordgo.VoiceState, *discordgo.Channel, bool) {
 	ca := CommandArgs{sess: sess, chO: ch, usrO: uid}
 
 	// check if user is in same channel
 	vch, vs, err := getVoiceChannel(sess, ch, uid)
-	if err != nil {
+	if err == errNotInVoice {
+		SendErrorTemp(ca, T(ms.guild, "music.notinvoice"), errorTimeout)
+		return nil, nil, false
+	} else if err != nil {
 		SendErrorTemp(ca, fmt.Sprintf("%s", err), errorTimeout)
 		return nil, nil, false
 	}
@@ -140,14 +198,14 @@ func getVoiceState(ms *musicSession, sess *discordgo.Session, ch string, uid str
 	ms.Unlock()
 
 	if playing && currentChan != nil && vch.ID != currentChan.ID {
-		SendErrorTemp(ca, "already playing in a different channel", errorTimeout)
+		SendErrorTemp(ca, T(ms.guild, "music.otherchannel"), errorTimeout)
 		return nil, nil, false
 	}
 
 	return vs, vch, true
 }
 
-func queueSong(ms *musicSession, sess *discordgo.Session, vs *discordgo.VoiceState, vch *discordgo.Channel, uid string, song *SongInfo) {
+func queueSong(ms *musicSession, sess Transport, vs *discordgo.VoiceState, vch *discordgo.Channel, uid string, song *SongInfo) {
 	ca := CommandArgs{sess: sess, chO: vch.ID, usrO: uid}
 
 	ms.Lock()
@@ -190,56 +248,30 @@ func init() {
 	listMutex = sync.Mutex{}
 	sessionList = make(map[string]*musicSession)
 
-	// load music settings
-	settingsjson, err := ioutil.ReadFile("./settings/music.json")
-	if err == nil {
-		err = json.Unmarshal(settingsjson, &settingsCache)
-		if err != nil {
-			fmt.Println("JSON error in music.json", err)
-		}
-	} else {
-		fmt.Println("Unable to read music.json, using empty")
-	}
-
-	// initialilze empty
-	if settingsCache.MusicChannels == nil {
-		settingsCache.MusicChannels = make(map[string]string)
-	}
-	if settingsCache.MusicEmbeds == nil {
-		settingsCache.MusicEmbeds = make(map[string]string)
-	}
-
 	// register commands
 	RegisterCommand(Command{
 		aliases: []string{"play", "p"},
-		regexes: []string{`[\s\S]+`},
+		module:  "music",
+		// after aliases so other commands still work in the music channel
+		regexes: []RegexRoute{{pattern: `[\s\S]+`, priority: -1}},
 		help: `play a song from url\n
 		command is optional: you can just paste in a URL\n
 		^%Pplay https://www.youtube.com/watch?v=asdf123^
 		^https://www.youtube.com/watch?v=asdf123^`,
-		noDM: true,
+		noDM:       true,
+		inChannel:  isMusicChannel,
+		middleware: []Middleware{deleteInvokingMiddleware},
+		cooldowns: []Cooldown{
+			{scope: CooldownUser, burst: 3, window: 30 * time.Second},
+			{scope: CooldownGuild, burst: 10, window: time.Minute},
+		},
 		callback: func(ca CommandArgs) bool {
-			if !isMusicChannel(ca) {
-				return false
-			}
-
 			// get url from args if not regex
 			url := ca.args
 			if ca.isRegex {
 				url = ca.content
-				// allowed commands in music channel
-				// TO DO: some kind of prefix to allow admin role to bypass?
-				if ca.alias == "play" || ca.alias == "p" ||
-					ca.alias == "volume" || ca.alias == "vol" ||
-					ca.alias == "seek" ||
-					ca.alias == "setmusic" {
-					return false
-				}
 			}
 
-			// delete user's message after completion so they don't get confused
-			defer ca.sess.ChannelMessageDelete(ca.msg.ChannelID, ca.msg.ID)
-
 			found := false
 			for _, r := range allowedLinks {
 				if regexp.MustCompile(r).MatchString(url) {
@@ -249,7 +281,7 @@ func init() {
 			}
 
 			if !found {
-				SendErrorTemp(ca, "not an allowed link", errorTimeout)
+				SendErrorTemp(ca, ca.T("music.badlink"), errorTimeout)
 				return true
 			}
 
@@ -264,12 +296,12 @@ func init() {
 			// note: ytdl is blocking!
 			song, err := YTDL(url)
 			if err != nil {
-				SendErrorTemp(ca, fmt.Sprintf("error querying song: %s", err), errorTimeout)
+				SendErrorTemp(ca, ca.T("music.error.query", "err", err), errorTimeout)
 				return true
 			}
 
 			if song.Duration == 0 {
-				SendErrorTemp(ca, "no streams allowed", errorTimeout)
+				SendErrorTemp(ca, ca.T("music.nostreams"), errorTimeout)
 				return true
 			}
 
@@ -282,6 +314,7 @@ func init() {
 
 	RegisterCommand(Command{
 		aliases: []string{"setmusic"},
+		module:  "music",
 		help: `marks this as the music channel\n
 		bot will only listen to this channel for requests
 		all music-related output will be in this channel
@@ -291,7 +324,7 @@ func init() {
 		roles:    []string{"botadmin"},
 		callback: func(ca CommandArgs) bool {
 			// keep note of old embed
-			oldem, ok := settingsCache.MusicEmbeds[ca.msg.GuildID]
+			oldem, ok := guildMusicEmbed(ca.msg.GuildID)
 
 			// set channel setting
 			setGuildMusicChannel(ca.msg.GuildID, ca.msg.ChannelID)
@@ -306,36 +339,26 @@ func init() {
 			// if ms is already created, musicChan is never set
 			// to the new channel!
 			getGuildSession(ca)
-
-			// delete message afterwards
-			ca.sess.ChannelMessageDelete(ca.msg.ChannelID, ca.msg.ID)
-
-			return false
-		}})
+			return true
+		},
+		middleware: []Middleware{deleteInvokingMiddleware}})
 
 	RegisterCommand(Command{
 		aliases: []string{"volume", "vol"},
+		module:  "music",
 		help: `change volume\n
 		^%Pvolume 0.5^`,
-		noDM: true,
+		params:       []Param{{name: "volume", kind: ParamFloat, min: 0.1, max: 1.5, help: "from 0.1 to 1.5"}},
+		noDM:         true,
+		errorTimeout: errorTimeout,
+		inChannel:    isMusicChannel,
+		middleware:   []Middleware{deleteInvokingMiddleware},
 		callback: func(ca CommandArgs) bool {
-			if !isMusicChannel(ca) {
-				return false
-			}
-			defer ca.sess.ChannelMessageDelete(ca.msg.ChannelID, ca.msg.ID)
-
-			vol, err := strconv.ParseFloat(ca.args, 64)
-			if err != nil {
-				SendErrorTemp(ca, fmt.Sprintf("couldn't parse volume: %s", err), errorTimeout)
-				return true
-			}
-			vol = ClampF(vol, 0.1, 1.5)
-
 			ms := getGuildSession(ca)
 
 			// TO DO: cleaner volume func on musicSession
 			ms.Lock()
-			ms.volume = vol
+			ms.volume = ca.Float("volume")
 			ms.Unlock()
 
 			ms.Restart(-1)
@@ -344,19 +367,102 @@ func init() {
 
 	RegisterCommand(Command{
 		aliases: []string{"seek"},
+		module:  "music",
 		help: `seek some time into the current song\n
-			^%Pseek 30^`,
-		noDM: true,
+			^%Pseek 30^
+			^%Pseek 1m30s^
+			^%Pseek 1:30^`,
+		params:       []Param{{name: "time", kind: ParamDuration}},
+		noDM:         true,
+		errorTimeout: errorTimeout,
+		inChannel:    isMusicChannel,
+		middleware:   []Middleware{deleteInvokingMiddleware},
 		callback: func(ca CommandArgs) bool {
-			if !isMusicChannel(ca) {
-				return false
-			}
-			defer ca.sess.ChannelMessageDelete(ca.msg.ChannelID, ca.msg.ID)
-
-			seek := ParseSeek(ca.args)
+			seek := int(ca.Duration("time").Seconds())
 
 			ms := getGuildSession(ca)
 			ms.Restart(seek)
 			return true
 		}})
+
+	RegisterCommand(Command{
+		aliases: []string{"queue", "q"},
+		module:  "music",
+		help: `show or manage the music queue\n
+		anyone can page through the queue, it's removed after a while`,
+		emptyArg:   true,
+		noDM:       true,
+		inChannel:  isMusicChannel,
+		middleware: []Middleware{deleteInvokingMiddleware},
+		callback: func(ca CommandArgs) bool {
+			ms := getGuildSession(ca)
+
+			ms.Lock()
+			var lines []string
+			for i, v := range ms.queue {
+				lines = append(lines, queueLine(i, v))
+			}
+			ms.Unlock()
+
+			if len(lines) == 0 {
+				SendErrorTemp(ca, ca.T("music.queue.empty"), errorTimeout)
+				return true
+			}
+			SendPaged(ca, PagedEmbed{
+				title:     ca.TN("music.queued", len(lines)),
+				pages:     paginate(lines, maxPageLength),
+				anyone:    true,
+				temporary: true,
+			})
+			return true
+		},
+		subcommands: []Command{
+			{
+				aliases: []string{"remove", "rm"},
+				help: `remove a song from the queue\n
+				^%Pqueue remove 3^`,
+				params:       []Param{{name: "position", kind: ParamInt, help: "position of the song in the queue"}},
+				errorTimeout: errorTimeout,
+				middleware:   []Middleware{deleteInvokingMiddleware},
+				callback: func(ca CommandArgs) bool {
+					ms := getGuildSession(ca)
+					pos := ca.Int("position") - 1
+
+					ms.Lock()
+					if pos < 0 || pos >= len(ms.queue) {
+						ms.Unlock()
+						SendErrorTemp(ca, ca.T("music.noposition"), errorTimeout)
+						return true
+					}
+					if pos == 0 && ms.playing {
+						ms.Unlock()
+						SendErrorTemp(ca, ca.T("music.playing"), errorTimeout)
+						return true
+					}
+					ms.queue = append(ms.queue[:pos], ms.queue[pos+1:]...)
+					ms.Unlock()
+
+					ms.updateEmbed()
+					return true
+				}},
+			{
+				aliases:    []string{"clear"},
+				help:       `remove all upcoming songs from the queue`,
+				emptyArg:   true,
+				middleware: []Middleware{deleteInvokingMiddleware},
+				callback: func(ca CommandArgs) bool {
+					ms := getGuildSession(ca)
+
+					ms.Lock()
+					if ms.playing && len(ms.queue) > 1 {
+						ms.queue = ms.queue[:1]
+					} else if !ms.playing {
+						ms.queue = nil
+					}
+					ms.Unlock()
+
+					ms.updateEmbed()
+					return true
+				}},
+		}})
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupMusic makes testMusic the music channel and fills the queue with songs
+func setupMusic(t *testing.T, f *FakeTransport, songs int) *musicSession {
+	t.Helper()
+	f.Receive(testMusic, testAdmin, "!setmusic")
+
+	ms := guildSession(f, testGuild)
+	if ms.embedBM == nil {
+		t.Fatal("no music embed")
+	}
+	ms.Lock()
+	for i := 1; i <= songs; i++ {
+		ms.queue = append(ms.queue, &SongInfo{Title: fmt.Sprintf("song %d", i), Duration: 3 * time.Minute, QueuedBy: "player"})
+	}
+	ms.Unlock()
+	return ms
+}
+
+func queueTitles(ms *musicSession) string {
+	ms.Lock()
+	defer ms.Unlock()
+
+	var titles []string
+	for _, s := range ms.queue {
+		titles = append(titles, strings.TrimPrefix(s.Title, "song "))
+	}
+	return strings.Join(titles, " ")
+}
+
+func TestMusicQueue(t *testing.T) {
+	tests := []struct {
+		name    string
+		playing bool
+		command string
+		queue   string
+		err     string
+	}{
+		{name: "remove", command: "!queue remove 2", queue: "1 3 4 5"},
+		{name: "remove alias", command: "!q rm 5", queue: "1 2 3 4"},
+		{name: "remove first", command: "!queue remove 1", queue: "2 3 4 5"},
+		{name: "remove playing", playing: true, command: "!queue remove 1", queue: "1 2 3 4 5", err: "song is currently playing, skip it instead"},
+		{name: "remove missing", command: "!queue remove 9", queue: "1 2 3 4 5", err: "no song at that position"},
+		{name: "clear", command: "!queue clear", queue: ""},
+		{name: "clear playing", playing: true, command: "!queue clear", queue: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestBot(t)
+			ms := setupMusic(t, f, 5)
+			ms.Lock()
+			ms.playing = tt.playing
+			ms.Unlock()
+
+			msg := f.Receive(testMusic, testPlayer, tt.command)
+			if got := queueTitles(ms); got != tt.queue {
+				t.Errorf("queue = %q, want %q", got, tt.queue)
+			}
+			if tt.err != "" {
+				if m := lastSent(t, f, testMusic); !isError(m) || embedText(m) != tt.err {
+					t.Errorf("got %q, want error %q", embedText(m), tt.err)
+				}
+			}
+
+			// the music channel only keeps the embed
+			if _, err := f.ChannelMessage(testMusic, msg.ID); err == nil {
+				t.Error("command wasn't deleted")
+			}
+		})
+	}
+}
+
+func TestMusicBadLinks(t *testing.T) {
+	f := newTestBot(t)
+	setupMusic(t, f, 0)
+
+	// the fourth is stopped by the play cooldown, but still deleted
+	for i := 0; i < 4; i++ {
+		msg := f.Receive(testMusic, testPlayer, "!play not a link")
+		if _, err := f.ChannelMessage(testMusic, msg.ID); err == nil {
+			t.Errorf("message %d wasn't deleted", i+1)
+		}
+	}
+
+	sent := f.SentTo(testMusic)
+	if m := sent[len(sent)-2]; embedText(m) != "not an allowed link" {
+		t.Errorf("got %q", embedText(m))
+	}
+	if m := sent[len(sent)-1]; !strings.HasPrefix(embedText(m), "slow down!") {
+		t.Errorf("got %q, want a cooldown", embedText(m))
+	}
+}
+
+func TestMusicQueuePages(t *testing.T) {
+	f := newTestBot(t)
+	ms := setupMusic(t, f, 100)
+
+	// the embed only has what fits
+	ms.Lock()
+	me := ms.makeEmbed()
+	ms.Unlock()
+	if len(*me.Content) > maxPageLength+100 || !strings.Contains(*me.Content, "more, `!queue` to see the whole queue") {
+		t.Errorf("embed content isn't cut short: %d characters", len(*me.Content))
+	}
+
+	f.Receive(testMusic, testPlayer, "!queue")
+	m := lastSent(t, f, testMusic)
+	if m.Embeds[0].Title != "100 songs in queue" {
+		t.Errorf("title = %q", m.Embeds[0].Title)
+	}
+	first := embedText(m)
+	if !strings.HasPrefix(first, "01.  **song 1**") {
+		t.Errorf("first page = %q", first)
+	}
+	waitFor(t, "page buttons", func() bool {
+		f.Lock()
+		defer f.Unlock()
+		return len(f.Reactions[m.ID]) == 3
+	})
+
+	// anyone can page through the queue
+	f.React(m, testGM.ID, "▶")
+	waitFor(t, "second page", func() bool {
+		f.Lock()
+		defer f.Unlock()
+		return m.Embeds[0].Description != first
+	})
+	if footer := m.Embeds[0].Footer.Text; !strings.HasPrefix(footer, "page 2/") {
+		t.Errorf("footer = %q", footer)
+	}
+
+	// and stop it, which deletes it from the music channel
+	f.React(m, testGM.ID, "⏹")
+	waitFor(t, "queue deleted", func() bool {
+		_, err := f.ChannelMessage(testMusic, m.ID)
+		return err != nil
+	})
+}
+
+func TestMusicVolumeRange(t *testing.T) {
+	for _, vol := range []string{"0", "0.05", "1.6", "3"} {
+		t.Run(vol, func(t *testing.T) {
+			f := newTestBot(t)
+			ms := setupMusic(t, f, 0)
+			ms.Lock()
+			before := ms.volume
+			ms.Unlock()
+
+			f.Receive(testMusic, testPlayer, "!volume "+vol)
+			m := lastSent(t, f, testMusic)
+			if !isError(m) || !strings.Contains(embedText(m), "`volume` must be between 0.1 and 1.5") {
+				t.Errorf("got %q", embedText(m))
+			}
+
+			ms.Lock()
+			defer ms.Unlock()
+			if ms.volume != before {
+				t.Errorf("volume changed to %v", ms.volume)
+			}
+		})
+	}
+}
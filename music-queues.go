@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// savedQueue is a guild's queue kept across restarts in queues.json
+//	the first song's Seek is where it was stopped
+type savedQueue struct {
+	VoiceChannel string
+	Songs        []*SongInfo
+}
+
+// queues loaded at startup that haven't been restored yet
+var savedQueuesMutex sync.Mutex
+var savedQueues = make(map[string]*savedQueue)
+
+var queuesFile = &settingsFile{name: "queues.json", version: 1}
+
+// saveQueues writes every guild's queue to queues.json,
+// including ones that haven't been restored yet
+func saveQueues() error {
+	queues := make(map[string]*savedQueue)
+
+	savedQueuesMutex.Lock()
+	for gid, sq := range savedQueues {
+		queues[gid] = sq
+	}
+	savedQueuesMutex.Unlock()
+
+	for _, ms := range musicSessions() {
+		ms.Lock()
+		if len(ms.queue) > 0 && ms.voiceChan != nil {
+			sq := &savedQueue{VoiceChannel: ms.voiceChan.ID}
+			for i, song := range ms.queue {
+				s := *song
+				if i == 0 && ms.playing {
+					s.Seek = int(ms.CurrentSeek().Seconds())
+				}
+				sq.Songs = append(sq.Songs, &s)
+			}
+			queues[ms.guild] = sq
+		}
+		ms.Unlock()
+	}
+
+	err := queuesFile.save(queues)
+	if err != nil {
+		settingsLog.Error("couldn't save queues", "err", err)
+	}
+	return err
+}
+
+// restoreQueue starts playing a guild's saved queue again
+//	called when the guild becomes available, stream URLs have
+//	probably expired so every song is looked up again
+func restoreQueue(sess Transport, gid string) {
+	defer recoverGoroutine(sess, "restoreQueue", gid, "")
+
+	savedQueuesMutex.Lock()
+	sq, ok := savedQueues[gid]
+	delete(savedQueues, gid)
+	savedQueuesMutex.Unlock()
+	if !ok {
+		return
+	}
+
+	log := settingsLog.With("guild", gid)
+	vch, err := lookupChannel(sess, sq.VoiceChannel)
+	if err != nil {
+		log.Warn("couldn't find voice channel to restore queue", "channel", sq.VoiceChannel, "err", err)
+		saveQueues()
+		return
+	}
+
+	ms := guildSession(sess, gid)
+	vs := &discordgo.VoiceState{GuildID: gid, ChannelID: vch.ID}
+	restored := 0
+	for _, saved := range sq.Songs {
+		song, err := YTDL(saved.URL)
+		if err != nil {
+			log.Warn("couldn't restore song", "url", saved.URL, "err", err)
+			continue
+		}
+		song.QueuedBy = saved.QueuedBy
+		song.Seek = saved.Seek
+		queueSong(ms, sess, vs, vch, "", song)
+		restored++
+	}
+	log.Info("restored queue", "songs", restored)
+	saveQueues()
+}
+
+func loadQueues() {
+	savedQueuesMutex.Lock()
+	defer savedQueuesMutex.Unlock()
+
+	// no queues.json just means nothing was playing
+	err := queuesFile.load(&savedQueues)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logLoadError(queuesFile.name, err)
+	}
+}
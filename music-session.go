@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -32,7 +33,7 @@ type musicSession struct {
 	ffmpeg    *FFMPEGSession
 	voiceConn *discordgo.VoiceConnection
 	voiceChan *discordgo.Channel
-	sess      *discordgo.Session
+	sess      Transport
 	guild     string
 	musicChan string
 	embedID   string
@@ -48,6 +49,39 @@ type musicSession struct {
 	running   bool
 }
 
+// musicSessionInfo is a snapshot of a music session for the control server
+type musicSessionInfo struct {
+	Guild        string
+	Shard        int
+	Playing      bool
+	Paused       bool
+	Looping      bool
+	VoiceChannel string `json:",omitempty"`
+	Position     string `json:",omitempty"`
+	Queue        []*SongInfo
+}
+
+func (ms *musicSession) info() musicSessionInfo {
+	ms.Lock()
+	defer ms.Unlock()
+
+	info := musicSessionInfo{
+		Guild:   ms.guild,
+		Shard:   shardID(ms.sess),
+		Playing: ms.playing,
+		Paused:  ms.paused,
+		Looping: ms.looping,
+		Queue:   append([]*SongInfo{}, ms.queue...),
+	}
+	if ms.voiceChan != nil {
+		info.VoiceChannel = ms.voiceChan.Name
+	}
+	if ms.playing {
+		info.Position = fmtDuration(ms.CurrentSeek())
+	}
+	return info
+}
+
 func (ms *musicSession) Play() {
 	ms.Lock()
 	defer ms.Unlock()
@@ -87,6 +121,12 @@ func (ms *musicSession) Skip() {
 }
 
 func (ms *musicSession) Stop() {
+	ms.stopPlayback()
+	ms.leaveVoice()
+}
+
+// stopPlayback clears the queue and stops ffmpeg without leaving voice
+func (ms *musicSession) stopPlayback() {
 	ms.Lock()
 	defer ms.Unlock()
 
@@ -94,12 +134,16 @@ func (ms *musicSession) Stop() {
 		ms.queue = nil
 		ms.ffmpeg.Stop()
 	}
+	ms.looping = false
+}
+
+func (ms *musicSession) leaveVoice() {
+	ms.Lock()
+	defer ms.Unlock()
 
 	if ms.voiceConn != nil && ms.voiceConn.Ready {
-		ms.voiceConn.Disconnect()
+		ms.sess.ChannelVoiceLeave(ms.voiceConn)
 	}
-
-	ms.looping = false
 }
 
 func (ms *musicSession) Loop() {
@@ -112,7 +156,7 @@ func (ms *musicSession) Loop() {
 
 func (ms *musicSession) Replay(caller *discordgo.Member) {
 	if caller == nil {
-		fmt.Println("no caller found for Replay")
+		NewLogger("music").With("guild", ms.guild).Warn("no caller found for Replay")
 		return
 	}
 
@@ -179,6 +223,8 @@ func (ms *musicSession) Restart(seek int) {
 }
 
 func (ms *musicSession) queueLoop() {
+	defer recoverGoroutine(ms.sess, "queueLoop", ms.guild, ms.musicChan)
+
 	ms.Lock()
 	if ms.running {
 		ms.Unlock()
@@ -192,7 +238,7 @@ func (ms *musicSession) queueLoop() {
 		select {
 		case err := <-ms.done:
 			if err != nil && !errors.Is(err, io.EOF) {
-				SendErrorTemp(CommandArgs{sess: ms.sess, chO: ms.musicChan}, fmt.Sprintf("ffmpeg session error: %s", err), errorTimeout)
+				SendErrorTemp(CommandArgs{sess: ms.sess, chO: ms.musicChan}, T(ms.guild, "music.error.ffmpeg", "err", err), errorTimeout)
 			}
 			ms.ffmpeg.Cleanup()
 
@@ -241,13 +287,23 @@ func fmtDuration(d time.Duration) string {
 	return fmt.Sprintf("%02d:%02d", m, s)
 }
 
+// queueLine formats a song at position i in the queue
+func queueLine(i int, song *SongInfo) string {
+	return fmt.Sprintf("%02d.  **%s** [%s]  `%s`", i+1, song.Title, fmtDuration(song.Duration), song.QueuedBy)
+}
+
 func (ms *musicSession) makeEmbed() *discordgo.MessageEdit {
 	me := &discordgo.MessageEdit{}
 
+	// only as much of the queue as fits, the queue command pages through all of it
 	queue := ""
 	for i, v := range ms.queue {
-		length := fmtDuration(v.Duration)
-		queue += fmt.Sprintf("%02d.  **%s** [%s]  `%s`\n", i+1, v.Title, length, v.QueuedBy)
+		line := queueLine(i, v) + "\n"
+		if len(queue)+len(line) > maxPageLength {
+			queue += formatTokens(TN(ms.guild, "music.more", len(ms.queue)-i), ms.guild)
+			break
+		}
+		queue += line
 	}
 	me.Content = &queue
 
@@ -258,23 +314,24 @@ func (ms *musicSession) makeEmbed() *discordgo.MessageEdit {
 		em.Title = fmt.Sprintf("%s [%s]", s.Title, length)
 		em.URL = s.URL
 		em.Image = &discordgo.MessageEmbedImage{URL: s.Thumbnail}
-		em.Description = fmt.Sprintf("queued by `%s`", s.QueuedBy)
+		em.Description = T(ms.guild, "music.queuedby", "user", s.QueuedBy)
 
-		looping := ""
+		footer := []string{
+			T(ms.guild, "music.time", "current", fmtDuration(ms.CurrentSeek()), "length", length),
+			TN(ms.guild, "music.updates", embedUpdateFreq),
+			T(ms.guild, "music.volume", "volume", fmt.Sprintf("%.2f", ms.volume)),
+			TN(ms.guild, "music.queued", len(ms.queue)),
+		}
 		if ms.looping {
-			looping = "\n(looping)"
+			footer = append(footer, T(ms.guild, "music.looping"))
 		}
-
-		paused := ""
 		if ms.paused {
-			paused = "\n(paused)"
+			footer = append(footer, T(ms.guild, "music.paused"))
 		}
-
-		em.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("current time: %s / %s\nupdates every %ds\nvolume: %.2f%s%s",
-			fmtDuration(ms.CurrentSeek()), length, embedUpdateFreq, ms.volume, looping, paused)}
+		em.Footer = &discordgo.MessageEmbedFooter{Text: strings.Join(footer, "\n")}
 	} else {
-		em.Title = "no song playing"
-		em.Description = "paste in a song link to begin"
+		em.Title = T(ms.guild, "music.idle.title")
+		em.Description = T(ms.guild, "music.idle")
 	}
 	me.Embed = em
 	return me
@@ -314,7 +371,7 @@ func (ms *musicSession) initEmbed() {
 		me := ms.makeEmbed()
 		newmsg, err := SendEmbed(CommandArgs{sess: ms.sess, chO: ms.musicChan}, me.Embed)
 		if err != nil {
-			SendErrorTemp(CommandArgs{sess: ms.sess, chO: ms.musicChan}, fmt.Sprintf("couldn't create embed: %s", err), errorTimeout)
+			SendErrorTemp(CommandArgs{sess: ms.sess, chO: ms.musicChan}, T(ms.guild, "music.error.embed", "err", err), errorTimeout)
 			ms.Unlock()
 			return
 		}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// how long a paged message keeps its buttons without a press
+var pageTimeout = 2 * time.Minute
+
+// longest page paginate makes, under discord's 2048 for an embed description
+//	so callers have room to wrap pages in code blocks
+var maxPageLength = 1800
+
+// PagedEmbed is a QEmbed with its content split into pages
+//	◀ and ▶ change page, ⏹ removes the buttons and keeps the current page
+//	- only whoever used the command can change page unless anyone is set
+//	- temporary deletes the message on ⏹ or when it times out instead of just
+//		removing the buttons, for channels like the music channel that should stay clean
+type PagedEmbed struct {
+	title     string
+	pages     []string
+	footer    string
+	colour    int
+	anyone    bool
+	temporary bool
+}
+
+// paginate joins lines into pages of up to maxLen characters
+//	lines longer than a page are clamped
+func paginate(lines []string, maxLen int) []string {
+	var pages []string
+	page := ""
+	for _, line := range lines {
+		line = ClampStr(line, maxLen)
+		if page != "" && len(page)+1+len(line) > maxLen {
+			pages = append(pages, page)
+			page = ""
+		}
+		if page != "" {
+			page += "\n"
+		}
+		page += line
+	}
+	if page != "" || len(pages) == 0 {
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// the embed for one page, with the page number in the footer
+func (pe PagedEmbed) embed(ca CommandArgs, page int) *discordgo.MessageEmbed {
+	footer := ca.T("page.footer", "page", page+1, "pages", len(pe.pages))
+	if pe.footer != "" {
+		footer = pe.footer + "\n" + footer
+	}
+	return &discordgo.MessageEmbed{Title: pe.title, Description: pe.pages[page], Color: pe.colour,
+		Footer: &discordgo.MessageEmbedFooter{Text: footer}}
+}
+
+// SendPaged sends the first page of a PagedEmbed with buttons to change page
+//	a single page is sent like QuickEmbed, without buttons
+func SendPaged(ca CommandArgs, pe PagedEmbed) (*discordgo.Message, error) {
+	if len(pe.pages) < 2 || (ca.isInteraction() && ca.ephemeral) {
+		content := ""
+		if len(pe.pages) > 0 {
+			content = pe.pages[0]
+		}
+		msg, err := QuickEmbed(ca, QEmbed{title: pe.title, content: content, footer: pe.footer, colour: pe.colour})
+		if err == nil && pe.temporary && !ca.isInteraction() {
+			// a message without buttons, so shutdown stops it like the others
+			bm := ButtonizeMessage(ca.sess, msg)
+			bm.Idle = pageTimeout
+			bm.OnIdle = deletePage
+			go bm.Listen()
+		}
+		return msg, err
+	}
+
+	msg, err := SendEmbed(ca, pe.embed(ca, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	invoker := ca.usrO
+	if invoker == "" && ca.msg != nil && ca.msg.Author != nil {
+		invoker = ca.msg.Author.ID
+	}
+	allowed := func(caller *discordgo.Member) bool {
+		return pe.anyone || (caller != nil && caller.User != nil && caller.User.ID == invoker)
+	}
+
+	page := 0
+	show := func() {
+		EditMessage(ca, &discordgo.MessageEdit{Channel: msg.ChannelID, ID: msg.ID, Embed: pe.embed(ca, page)})
+	}
+
+	bm := ButtonizeMessage(ca.sess, msg)
+	bm.Idle = pageTimeout
+	if pe.temporary {
+		bm.OnIdle = deletePage
+	}
+
+	// handlers run one at a time on the Listen goroutine, so page doesn't need a lock
+	go func() {
+		bm.AddHandler("◀", func(bm *ButtonizedMessage, caller *discordgo.Member) {
+			if !allowed(caller) || page == 0 {
+				return
+			}
+			page--
+			show()
+		})
+		bm.AddHandler("▶", func(bm *ButtonizedMessage, caller *discordgo.Member) {
+			if !allowed(caller) || page == len(pe.pages)-1 {
+				return
+			}
+			page++
+			show()
+		})
+		bm.AddHandler("⏹", func(bm *ButtonizedMessage, caller *discordgo.Member) {
+			if !allowed(caller) {
+				return
+			}
+			if pe.temporary {
+				deletePage(bm)
+			} else {
+				bm.Sess.MessageReactionsRemoveAll(bm.Msg.ChannelID, bm.Msg.ID)
+			}
+			bm.Close <- true
+		})
+		bm.Listen()
+	}()
+	return msg, nil
+}
+
+// deletePage deletes a temporary paged message
+func deletePage(bm *ButtonizedMessage) {
+	bm.Sess.ChannelMessageDelete(bm.Msg.ChannelID, bm.Msg.ID)
+}
+
+// codeBlocks wraps each page in a code block
+func codeBlocks(pages []string) []string {
+	out := make([]string, len(pages))
+	for i, p := range pages {
+		out[i] = "```" + p + "```"
+	}
+	return out
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+var panicLog = NewLogger("panic")
+
+// panicContext is where a panic happened, for the report
+type panicContext struct {
+	where   string
+	guild   string
+	channel string
+	user    string
+	command string
+	message string
+}
+
+// commandPanicContext describes the command and message in ca
+func commandPanicContext(ca CommandArgs) panicContext {
+	pc := panicContext{where: "command"}
+	if ca.msg != nil {
+		pc.guild = ca.msg.GuildID
+		pc.channel = ca.msg.ChannelID
+		pc.message = ca.msg.Content
+		if ca.msg.Author != nil {
+			pc.user = fmt.Sprintf("%s (%s)", ca.msg.Author.Username, ca.msg.Author.ID)
+		}
+	}
+	if ca.cmd != nil {
+		pc.command = ca.cmd.path
+	}
+	return pc
+}
+
+// repeats of a panic within this long are counted instead of reported
+var panicDedupeWindow = time.Hour
+
+// reports longer than this are sent as a file
+var panicInlineLength = 1800
+
+type panicRecord struct {
+	count    int
+	reported time.Time
+}
+
+var panicMutex sync.Mutex
+var panicsSeen = make(map[string]*panicRecord)
+
+// goroutine IDs, arguments and offsets change between otherwise identical panics
+var panicArgsRx = regexp.MustCompile(`\(.*\)$|\s\+0x[0-9a-f]+$`)
+
+func panicKey(where string, r interface{}, stack []byte) string {
+	lines := strings.Split(string(stack), "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "goroutine ") {
+		lines = lines[1:]
+	}
+	for i, l := range lines {
+		lines[i] = panicArgsRx.ReplaceAllString(l, "")
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s\n%v\n%s", where, r, strings.Join(lines, "\n"))))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// notes a panic and returns whether it should be reported
+// and how many times it happened since the last report
+func countPanic(key string) (bool, int) {
+	panicMutex.Lock()
+	defer panicMutex.Unlock()
+
+	now := time.Now()
+	rec, ok := panicsSeen[key]
+	if !ok {
+		panicsSeen[key] = &panicRecord{count: 0, reported: now}
+		return true, 1
+	}
+
+	rec.count++
+	if now.Sub(rec.reported) < panicDedupeWindow {
+		return false, rec.count
+	}
+	count := rec.count
+	rec.count = 0
+	rec.reported = now
+	return true, count
+}
+
+// recoverPanic reports a panic while handling a command
+//	must be deferred directly so recover works
+func recoverPanic(ca CommandArgs) {
+	if r := recover(); r != nil {
+		handlePanic(ca.sess, commandPanicContext(ca), r, debug.Stack())
+	}
+}
+
+// recoverGoroutine reports a panic in a long-running goroutine so it doesn't take the bot down
+//	must be deferred directly so recover works
+func recoverGoroutine(sess Transport, where string, guild string, channel string) {
+	if r := recover(); r != nil {
+		handlePanic(sess, panicContext{where: where, guild: guild, channel: channel}, r, debug.Stack())
+	}
+}
+
+// reportPanic reports a panic recovered while running a command
+func reportPanic(ca CommandArgs, r interface{}, stack []byte) {
+	handlePanic(ca.sess, commandPanicContext(ca), r, stack)
+}
+
+// handlePanic logs a panic with its full stack and DMs it to the owners,
+// repeats are only counted until panicDedupeWindow has passed
+func handlePanic(sess Transport, pc panicContext, r interface{}, stack []byte) {
+	key := panicKey(pc.where, r, stack)
+	report, count := countPanic(key)
+
+	log := panicLog.With("where", pc.where).With("id", key)
+	if pc.guild != "" {
+		log = log.With("guild", pc.guild)
+	}
+	if pc.channel != "" {
+		log = log.With("channel", pc.channel)
+	}
+	if pc.command != "" {
+		log = log.With("command", pc.command)
+	}
+
+	if !report {
+		log.Error("recovered repeated panic", "panic", r, "count", count)
+		return
+	}
+	log.Error("recovered panic", "panic", r, "user", pc.user, "message", pc.message, "stack", string(stack))
+
+	if !Config().SendErrors || sess == nil {
+		return
+	}
+
+	text := formatPanicReport(sess, pc, r, stack, key, count)
+	for _, owner := range Owners() {
+		ch, err := GetDMChannel(sess, owner)
+		if err != nil {
+			log.Error("error DMing owner panic log", "owner", owner, "err", err)
+			continue
+		}
+
+		ca := CommandArgs{sess: sess, chO: ch.ID}
+		if len(text) <= panicInlineLength {
+			SendReply(ca, text)
+			continue
+		}
+
+		summary := strings.SplitN(text, "```", 2)[0]
+		SendReply(ca, ClampStr(summary, panicInlineLength)+"full report attached")
+		SendFile(ca, fmt.Sprintf("panic-%s.txt", key), strings.NewReader(text))
+	}
+}
+
+func formatPanicReport(sess Transport, pc panicContext, r interface{}, stack []byte, key string, count int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "`<Recovered panic in %s>` `%s`\n", pc.where, key)
+	fmt.Fprintf(&b, "**%s**\n", ClampStr(fmt.Sprint(r), 300))
+	if count > 1 {
+		fmt.Fprintf(&b, "happened %d times since last reported\n", count)
+	}
+	if pc.guild != "" {
+		name := pc.guild
+		if g, err := sess.State().Guild(pc.guild); err == nil {
+			name = fmt.Sprintf("%s (%s)", g.Name, g.ID)
+		}
+		fmt.Fprintf(&b, "guild: %s\n", name)
+	}
+	if pc.channel != "" {
+		fmt.Fprintf(&b, "channel: #%s (%s)\n", GetChannelName(sess, pc.channel), pc.channel)
+	}
+	if pc.user != "" {
+		fmt.Fprintf(&b, "user: %s\n", pc.user)
+	}
+	if pc.command != "" {
+		fmt.Fprintf(&b, "command: %s\n", pc.command)
+	}
+	if pc.message != "" {
+		fmt.Fprintf(&b, "message: `%s`\n", strings.Replace(ClampStr(pc.message, 300), "`", "'", -1))
+	}
+	fmt.Fprintf(&b, "```%s```", stack)
+	return b.String()
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// permRule grants a permission node in a guild
+//	anyone with one of the roles, one of the user IDs,
+//	or any of the discord permission bits is allowed
+type permRule struct {
+	Roles []string `json:",omitempty"`
+	Users []string `json:",omitempty"`
+	Perms int64    `json:",omitempty"`
+}
+
+func (r *permRule) empty() bool {
+	return len(r.Roles) == 0 && len(r.Users) == 0 && r.Perms == 0
+}
+
+// discord permissions a node falls back to when a guild hasn't set a rule for it
+var defaultPermissions = map[string]int64{
+	"botadmin": discordgo.PermissionManageServer,
+}
+
+// discord permission names that can be granted with the perms command
+var permissionNames = map[string]int64{
+	"administrator":  discordgo.PermissionAdministrator,
+	"manageserver":   discordgo.PermissionManageServer,
+	"managechannels": discordgo.PermissionManageChannels,
+	"manageroles":    discordgo.PermissionManageRoles,
+	"managemessages": discordgo.PermissionManageMessages,
+	"kickmembers":    discordgo.PermissionKickMembers,
+	"banmembers":     discordgo.PermissionBanMembers,
+	"moderate":       discordgo.PermissionModerateMembers,
+}
+
+// commandNode returns the permission node for a command, ie "clock.create"
+func commandNode(cmd Command) string {
+	return strings.Replace(cmd.path, " ", ".", -1)
+}
+
+// permissionNodes returns every node that can be given a rule
+func permissionNodes() []string {
+	seen := make(map[string]bool)
+	for node := range defaultPermissions {
+		seen[node] = true
+	}
+
+	var walk func(cmd Command)
+	walk = func(cmd Command) {
+		seen[commandNode(cmd)] = true
+		for _, r := range cmd.roles {
+			seen[r] = true
+		}
+		for _, sub := range cmd.subcommands {
+			walk(sub)
+		}
+	}
+	for _, cmd := range CommandList {
+		walk(cmd)
+	}
+
+	var nodes []string
+	for node := range seen {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+func isPermissionNode(node string) bool {
+	for _, n := range permissionNodes() {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// guildPermRule returns a copy of a guild's rule for a node
+func guildPermRule(gid string, node string) (permRule, bool) {
+	guildConfigMutex.Lock()
+	defer guildConfigMutex.Unlock()
+
+	gc, ok := guildConfigs[gid]
+	if !ok {
+		return permRule{}, false
+	}
+	rule, ok := gc.Permissions[node]
+	if !ok {
+		return permRule{}, false
+	}
+	return *rule, true
+}
+
+// memberPermissions returns a member's discord permissions in a channel
+//	or in the guild as a whole if the channel isn't known
+func memberPermissions(sess Transport, mem *discordgo.Member, chid string) int64 {
+	if chid != "" {
+		perms, err := sess.State().UserChannelPermissions(mem.User.ID, chid)
+		if err == nil {
+			return perms
+		}
+	}
+
+	guild, err := lookupGuild(sess, mem.GuildID)
+	if err != nil {
+		return 0
+	}
+	if guild.OwnerID == mem.User.ID {
+		return discordgo.PermissionAll
+	}
+
+	var perms int64
+	for _, role := range guild.Roles {
+		if role.ID == guild.ID {
+			perms |= role.Permissions
+			continue
+		}
+		for _, mr := range mem.Roles {
+			if mr == role.ID {
+				perms |= role.Permissions
+			}
+		}
+	}
+	return perms
+}
+
+func hasDiscordPermission(perms int64, want int64) bool {
+	if perms&discordgo.PermissionAdministrator != 0 {
+		return true
+	}
+	return perms&want != 0
+}
+
+func ruleAllows(sess Transport, rule permRule, mem *discordgo.Member, chid string) bool {
+	for _, uid := range rule.Users {
+		if uid == mem.User.ID {
+			return true
+		}
+	}
+	for _, rid := range rule.Roles {
+		for _, mr := range mem.Roles {
+			if mr == rid {
+				return true
+			}
+		}
+	}
+	if rule.Perms != 0 && hasDiscordPermission(memberPermissions(sess, mem, chid), rule.Perms) {
+		return true
+	}
+	return false
+}
+
+// HasPermission checks if a member has a permission node
+//	a guild's rule for the node is used if there is one, otherwise
+//	the default discord permissions and a role named after the node are
+func HasPermission(sess Transport, mem *discordgo.Member, chid string, node string) bool {
+	if mem == nil {
+		return false
+	}
+
+	rule, ok := guildPermRule(mem.GuildID, node)
+	if ok {
+		return ruleAllows(sess, rule, mem, chid)
+	}
+
+	if want, ok := defaultPermissions[node]; ok && hasDiscordPermission(memberPermissions(sess, mem, chid), want) {
+		return true
+	}
+	return HasRole(sess, mem, node)
+}
+
+// FindMembersWithPermission returns members in a guild who have a permission node
+func FindMembersWithPermission(sess Transport, gid string, node string) ([]*discordgo.Member, error) {
+	members, err := guildMembers(sess, gid)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*discordgo.Member
+	for _, m := range members {
+		if HasPermission(sess, m, "", node) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// resolves a grant/revoke target into a rule with just that target in it
+func parsePermTarget(ca CommandArgs, target string) (permRule, string, error) {
+	if m := userMentionRx.FindStringSubmatch(target); m != nil {
+		return permRule{Users: []string{m[1]}}, target, nil
+	}
+	if m := roleMentionRx.FindStringSubmatch(target); m != nil {
+		return permRule{Roles: []string{m[1]}}, target, nil
+	}
+	if perm, ok := permissionNames[strings.ToLower(target)]; ok {
+		return permRule{Perms: perm}, strings.ToLower(target), nil
+	}
+	if snowflakeRx.MatchString(target) {
+		if role, err := ca.sess.State().Role(ca.msg.GuildID, target); err == nil {
+			return permRule{Roles: []string{role.ID}}, role.Name, nil
+		}
+		return permRule{Users: []string{target}}, fmt.Sprintf("<@%s>", target), nil
+	}
+	if role, err := GetRole(ca.sess, ca.msg.GuildID, target); err == nil {
+		return permRule{Roles: []string{role.ID}}, role.Name, nil
+	}
+	return permRule{}, "", errors.New(ca.T("perms.target"))
+}
+
+func addStrings(list []string, strs []string) []string {
+	for _, str := range strs {
+		list = append(removeString(list, str), str)
+	}
+	return list
+}
+
+func removeString(list []string, str string) []string {
+	var out []string
+	for _, v := range list {
+		if v != str {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// describes a rule for the perms list
+func describeRule(rule *permRule) string {
+	var parts []string
+	for _, rid := range rule.Roles {
+		parts = append(parts, fmt.Sprintf("<@&%s>", rid))
+	}
+	for _, uid := range rule.Users {
+		parts = append(parts, fmt.Sprintf("<@%s>", uid))
+	}
+	var names []string
+	for name, bit := range permissionNames {
+		if rule.Perms&bit != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(append(parts, names...), ", ")
+}
+
+func init() {
+	nodeParam := Param{name: "node", help: "command like ^clock.create^ or role node like ^gm^"}
+
+	RegisterCommand(Command{
+		aliases: []string{"perms", "permissions"},
+		help: `show or change who can use commands in this server\n
+		a rule on a node replaces the default of a role named after the node
+		^%Pperms grant gm @Storyteller^
+		^%Pperms grant roll @someone^
+		^%Pperms grant botadmin manageserver^`,
+		emptyArg: true,
+		noDM:     true,
+		roles:    []string{"botadmin"},
+		callback: func(ca CommandArgs) bool {
+			guildConfigMutex.Lock()
+			var lines []string
+			if gc, ok := guildConfigs[ca.msg.GuildID]; ok {
+				for node, rule := range gc.Permissions {
+					lines = append(lines, fmt.Sprintf("`%s` - %s", node, describeRule(rule)))
+				}
+			}
+			guildConfigMutex.Unlock()
+
+			if len(lines) == 0 {
+				QuickEmbed(ca, QEmbed{title: ca.T("perms.title"), content: ca.T("perms.none")})
+				return false
+			}
+			sort.Strings(lines)
+			QuickEmbed(ca, QEmbed{title: ca.T("perms.title"), content: strings.Join(lines, "\n")})
+			return false
+		},
+		subcommands: []Command{
+			{
+				aliases: []string{"grant", "allow"},
+				help: `grant a node to a role, user or discord permission\n
+				^%Pperms grant clock.create @Storyteller^`,
+				params: []Param{nodeParam, {name: "target", kind: ParamRest, help: "role, user or permission name"}},
+				callback: func(ca CommandArgs) bool {
+					node := strings.ToLower(ca.Str("node"))
+					if !isPermissionNode(node) {
+						SendError(ca, ca.T("perms.unknown"))
+						return false
+					}
+
+					target, name, err := parsePermTarget(ca, ca.Str("target"))
+					if err != nil {
+						SendError(ca, err.Error())
+						return false
+					}
+
+					updateGuildConfig(ca.msg.GuildID, func(gc *guildConfig) {
+						if gc.Permissions == nil {
+							gc.Permissions = make(map[string]*permRule)
+						}
+						rule, ok := gc.Permissions[node]
+						if !ok {
+							rule = &permRule{}
+							gc.Permissions[node] = rule
+						}
+						rule.Roles = addStrings(rule.Roles, target.Roles)
+						rule.Users = addStrings(rule.Users, target.Users)
+						rule.Perms |= target.Perms
+					})
+					QuickEmbed(ca, QEmbed{content: ca.T("perms.granted", "node", node, "target", name)})
+					return false
+				}},
+			{
+				aliases: []string{"revoke", "deny"},
+				help: `revoke a node from a role, user or discord permission\n
+				^%Pperms revoke clock.create @Storyteller^`,
+				params: []Param{nodeParam, {name: "target", kind: ParamRest, help: "role, user or permission name"}},
+				callback: func(ca CommandArgs) bool {
+					node := strings.ToLower(ca.Str("node"))
+					target, name, err := parsePermTarget(ca, ca.Str("target"))
+					if err != nil {
+						SendError(ca, err.Error())
+						return false
+					}
+
+					found := false
+					updateGuildConfig(ca.msg.GuildID, func(gc *guildConfig) {
+						rule, ok := gc.Permissions[node]
+						if !ok {
+							return
+						}
+						found = true
+						for _, rid := range target.Roles {
+							rule.Roles = removeString(rule.Roles, rid)
+						}
+						for _, uid := range target.Users {
+							rule.Users = removeString(rule.Users, uid)
+						}
+						rule.Perms &^= target.Perms
+
+						// nothing left, go back to the default
+						if rule.empty() {
+							delete(gc.Permissions, node)
+						}
+					})
+					if !found {
+						SendError(ca, ca.T("perms.norule"))
+						return false
+					}
+					QuickEmbed(ca, QEmbed{content: ca.T("perms.revoked", "node", node, "target", name)})
+					return false
+				}},
+			{
+				aliases: []string{"reset"},
+				help: `remove a node's rule and go back to the default\n
+				^%Pperms reset gm^`,
+				params: []Param{nodeParam},
+				callback: func(ca CommandArgs) bool {
+					node := strings.ToLower(ca.Str("node"))
+					updateGuildConfig(ca.msg.GuildID, func(gc *guildConfig) {
+						delete(gc.Permissions, node)
+					})
+					QuickEmbed(ca, QEmbed{content: ca.T("perms.reset", "node", node)})
+					return false
+				}},
+			{
+				aliases:  []string{"nodes"},
+				help:     `list nodes that rules can be set for`,
+				emptyArg: true,
+				callback: func(ca CommandArgs) bool {
+					QuickEmbed(ca, QEmbed{title: ca.T("perms.nodes"), content: fmt.Sprintf("```%s```", strings.Join(permissionNodes(), "\n"))})
+					return false
+				}},
+		}})
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math"
 	"regexp"
 	"strconv"
@@ -172,231 +171,296 @@ type guildClockSettings struct {
 	Style  string
 }
 
-var clockSettingsCache = make(map[string]*guildClockSettings)
+// clock settings per guild
+//	"clocks" is a []*clock, "style" is the style name
+var clockStorage = NewStorage("clocks", ScopeGuild, clocksFile)
 
-func loadClockSettings() {
-	js, err := ioutil.ReadFile("./settings/clocks.json")
-	if err == nil {
-		err = json.Unmarshal(js, &clockSettingsCache)
+// version 2 moved clocks.json onto Storage
+var clocksFile = &settingsFile{name: "clocks.json", version: 2, migrations: map[int]func(json.RawMessage) (json.RawMessage, error){
+	1: func(data json.RawMessage) (json.RawMessage, error) {
+		var old map[string]*guildClockSettings
+		err := json.Unmarshal(data, &old)
 		if err != nil {
-			fmt.Println("JSON error in clocks.json", err)
+			return nil, err
 		}
-	} else {
-		fmt.Println("Unable to read clocks.json, using empty")
-	}
-}
+		guilds := make(map[string]map[string]interface{})
+		for gid, gset := range old {
+			guilds[gid] = map[string]interface{}{"clocks": gset.Clocks, "style": gset.Style}
+		}
+		return json.Marshal(map[StorageScope]interface{}{ScopeGuild: guilds})
+	},
+}}
 
-func saveClockSettings() {
-	// save json
-	b, err := json.Marshal(clockSettingsCache)
+var errNoClock = errors.New("clock not found")
+
+func guildClockStyle(gid string) string {
+	style := "circle"
+	_, err := clockStorage.Get(gid, "style", &style)
 	if err != nil {
-		fmt.Println("Error marshaling JSON for clocks.json", err)
-		return
+		settingsLog.Error("couldn't get clock style", "guild", gid, "err", err)
 	}
-	err = ioutil.WriteFile("./settings/clocks.json", b, 0644)
+	return style
+}
+
+func guildClocks(gid string) []*clock {
+	var clocks []*clock
+	_, err := clockStorage.Get(gid, "clocks", &clocks)
 	if err != nil {
-		fmt.Println("Error saving clocks.json", err)
-		return
+		settingsLog.Error("couldn't get clocks", "guild", gid, "err", err)
 	}
+	return clocks
 }
 
-func guildSettings(gid string) *guildClockSettings {
-	_, ok := clockSettingsCache[gid]
-	if !ok {
-		clockSettingsCache[gid] = &guildClockSettings{}
-		g := clockSettingsCache[gid]
-		g.Style = "circle"
-	}
-	return clockSettingsCache[gid]
+// clockSettings returns a guild's clocks and style together
+func clockSettings(gid string) guildClockSettings {
+	return guildClockSettings{Clocks: guildClocks(gid), Style: guildClockStyle(gid)}
 }
 
-func getClock(gid string, name string) *clock {
+// findClock finds a clock to show by name or partial name
+//	a whole name wins over a partial one
+func findClock(clocks []*clock, name string) *clock {
+	if cl := findClockExact(clocks, name); cl != nil {
+		return cl
+	}
 	name = strings.ToLower(name)
-	gset := guildSettings(gid)
-	for _, c := range gset.Clocks {
-		cn := strings.ToLower(c.Name)
-		if cn == name || strings.HasPrefix(cn, name) {
+	for _, c := range clocks {
+		if strings.HasPrefix(strings.ToLower(c.Name), name) {
 			return c
 		}
 	}
 	return nil
 }
 
-func init() {
-	loadClockSettings()
+// findClockExact finds a clock by its whole name, ignoring case
+//	used by anything that changes clocks, so a partial name can't change the wrong one
+func findClockExact(clocks []*clock, name string) *clock {
+	for _, c := range clocks {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// sends errNoClock or an error saving clocks
+func sendClockError(ca CommandArgs, err error) {
+	if errors.Is(err, errNoClock) {
+		SendError(ca, ca.T("clock.notfound"))
+		return
+	}
+	SendError(ca, ca.T("clock.error.save", "err", err))
+}
 
+// renders a single clock and sends it as an image
+func sendClock(ca CommandArgs, style string, cl clock) {
+	ctx, err := createClock(style, float64(cl.Slices), float64(cl.Ticked), cl.Name)
+	if err != nil {
+		SendError(ca, ca.T("clock.error.create", "err", err))
+		return
+	}
+	SendFile(ca, fmt.Sprintf("clock_%s.png", time.Now()), writePNG(ctx))
+}
+
+func init() {
 	RegisterCommand(Command{
 		aliases: []string{"clockstyle"},
+		module:  "rpg-clocks",
 		help: `set clock style\n
 		valid styles:
 		 - ^circle^
 		 - ^spikes^`,
-		noDM:  true,
-		roles: []string{"gm", "botadmin"},
+		params: []Param{{name: "style", kind: ParamEnum, choices: []string{"circle", "spikes"}}},
+		noDM:   true,
+		roles:  []string{"gm", "botadmin"},
 		callback: func(ca CommandArgs) bool {
-			if ca.args != "circle" && ca.args != "spikes" {
-				SendError(ca, "not a valid clock style\nsee ^%Phelp clockstyle^ for valid styles")
+			err := clockStorage.Set(ca.msg.GuildID, "style", ca.Str("style"))
+			if err != nil {
+				SendError(ca, ca.T("clock.error.style", "err", err))
 				return false
 			}
 
-			gset := guildSettings(ca.msg.GuildID)
-			gset.Style = ca.args
-
-			QuickEmbed(ca, QEmbed{content: "clock style set"})
-			saveClockSettings()
+			QuickEmbed(ca, QEmbed{content: ca.T("clock.styleset")})
 			return false
 		}})
 
 	RegisterCommand(Command{
 		aliases: []string{"clock"},
+		module:  "rpg-clocks",
 		help: `display or manipulate a clock\n
 		^%Pclock something happens^ - display a clock by name
-		^%Pclock someth^ - display a clock by partial name
-		^%Pclock name 4^ - create clock with 4 slices
-		^%Pclock name 1/4^ - create or update clock with 1/4 slices
-		^%Pclock name +2^ - increase clock by 2 tick
-		^%Pclock name -1^ - decrease clock by 1 tick
-		^%Pclock name delete^ - delete a clock
-		^%Pclock name del^ - delete a clock`,
-		noDM: true,
+		^%Pclock someth^ - display a clock by partial name`,
+		params:    []Param{{name: "name", kind: ParamRest}},
+		noDM:      true,
+		cooldowns: []Cooldown{{scope: CooldownChannel, burst: 5, window: 10 * time.Second}},
 		callback: func(ca CommandArgs) bool {
-			// parse argument string
-			fields := strings.Fields(ca.args)
-			last := fields[len(fields)-1]
-
-			action := "show"
-			if last != ca.args {
-				if last == "del" || last == "delete" {
-					action = "delete"
-				} else if regexp.MustCompile(`[+-]\d+`).MatchString(last) {
-					action = "offset"
-				} else if regexp.MustCompile(`(\d+\/\d+|\d+)`).MatchString(last) {
-					action = "create"
-				}
-			}
-
-			// only allow the gm to manipulate
-			if action != "show" && !HasRole(ca.sess, ca.msg.Member, "gm") {
+			cl := findClock(guildClocks(ca.msg.GuildID), ca.Str("name"))
+			if cl == nil {
+				SendError(ca, ca.T("clock.notfound"))
 				return false
 			}
-
-			name := strings.Join(fields, " ")
-			if action != "show" {
-				name = strings.Join(fields[:len(fields)-1], " ")
-			}
-
-			// get clock
-			var cl *clock
-			gcl := getClock(ca.msg.GuildID, name)
-			if gcl == nil {
-				// don't error on nil clock if we're making a new one anyway
-				if action != "create" {
-					SendError(ca, "clock not found")
-					return false
-				}
-			}
-			// set clock if not nil
-			if gcl != nil {
-				cl = gcl
-			}
-
-			// handle actions
-			if action == "delete" {
-				gset := guildSettings(ca.msg.GuildID)
-				for i, c := range gset.Clocks {
-					if c.Name == cl.Name {
-						gset.Clocks = append(gset.Clocks[:i], gset.Clocks[i+1:]...)
-						break
+			sendClock(ca, guildClockStyle(ca.msg.GuildID), *cl)
+			return false
+		},
+		subcommands: []Command{
+			{
+				aliases: []string{"create", "new", "set"},
+				help: `create or update a clock\n
+				^%Pclock create 4 name^ - create clock with 4 slices
+				^%Pclock create 1/4 name^ - create or update clock with 1/4 slices`,
+				params: []Param{
+					{name: "size", pattern: `^(\d+\/)?\d+$`, help: "number of slices, or ticked/slices"},
+					{name: "name", kind: ParamRest},
+				},
+				roles: []string{"gm"},
+				callback: func(ca CommandArgs) bool {
+					ticked := 0
+					slices := 4
+
+					// parse slices & ticked
+					size := ca.Str("size")
+					rx := regexp.MustCompile(`(\d+)\/(\d+)`)
+					if rx.MatchString(size) { // "1/4"
+						strTicked := rx.FindAllStringSubmatch(size, -1)[0][1]
+						strSlices := rx.FindAllStringSubmatch(size, -1)[0][2]
+
+						iSlices, err := strconv.Atoi(strSlices)
+						if err != nil {
+							SendError(ca, ca.T("clock.error.slices"))
+							return false
+						}
+
+						iTicked, err := strconv.Atoi(strTicked)
+						if err != nil {
+							SendError(ca, ca.T("clock.error.ticked"))
+							return false
+						}
+
+						slices = iSlices
+						ticked = iTicked
+					} else { // "4" = 0/4
+						iSlices, err := strconv.Atoi(size)
+						if err != nil {
+							SendError(ca, ca.T("clock.error.slices"))
+							return false
+						}
+						slices = iSlices
 					}
-				}
-				saveClockSettings()
-				QuickEmbed(ca, QEmbed{content: fmt.Sprintf("`%s (%d/%d)` deleted", cl.Name, cl.Ticked, cl.Slices)})
-				return false // don't show clock afterwards
-			} else if action == "offset" {
-				offset, err := strconv.Atoi(last)
-				if err != nil {
-					SendError(ca, "couldn't parse offset")
-					return false
-				}
 
-				cl.Ticked = ClampI(cl.Ticked+offset, 0, cl.Slices)
-				saveClockSettings()
-			} else if action == "create" {
-				ticked := 0
-				slices := 4
-
-				// parse slices & ticked
-				rx := regexp.MustCompile(`(\d+)\/(\d+)`)
-				if rx.MatchString(last) { // "1/4"
-					strTicked := rx.FindAllStringSubmatch(last, -1)[0][1]
-					strSlices := rx.FindAllStringSubmatch(last, -1)[0][2]
+					// a clock with no slices or more ticked than slices can't be drawn
+					if slices < 1 || ticked > slices {
+						SendError(ca, ca.T("args.invalid", "param", "size", "err", ca.T("clock.error.size")))
+						return false
+					}
 
-					iSlices, err := strconv.Atoi(strSlices)
+					name := ca.Str("name")
+					var clocks []*clock
+					var shown clock
+					err := clockStorage.Update(ca.msg.GuildID, "clocks", &clocks, func(bool) error {
+						cl := findClockExact(clocks, name)
+						if cl != nil {
+							// update existing clock
+							cl.Ticked = ticked
+							cl.Slices = slices
+						} else {
+							// create new clock
+							cl = &clock{Name: name, Ticked: ticked, Slices: slices}
+							clocks = append(clocks, cl)
+						}
+						shown = *cl
+						return nil
+					})
 					if err != nil {
-						SendError(ca, "couldn't parse slice count")
+						sendClockError(ca, err)
 						return false
 					}
 
-					iTicked, err := strconv.Atoi(strTicked)
+					sendClock(ca, guildClockStyle(ca.msg.GuildID), shown)
+					return false
+				}},
+			{
+				aliases: []string{"tick", "t"},
+				help: `tick a clock up or down\n
+				^%Pclock tick +2 name^ - increase clock by 2 ticks
+				^%Pclock tick -1 name^ - decrease clock by 1 tick`,
+				params: []Param{
+					{name: "amount", kind: ParamInt},
+					{name: "name", kind: ParamRest},
+				},
+				roles: []string{"gm"},
+				callback: func(ca CommandArgs) bool {
+					var clocks []*clock
+					var shown clock
+					err := clockStorage.Update(ca.msg.GuildID, "clocks", &clocks, func(bool) error {
+						cl := findClockExact(clocks, ca.Str("name"))
+						if cl == nil {
+							return errNoClock
+						}
+						cl.Ticked = ClampI(cl.Ticked+ca.Int("amount"), 0, cl.Slices)
+						shown = *cl
+						return nil
+					})
 					if err != nil {
-						SendError(ca, "couldn't parse ticked count")
+						sendClockError(ca, err)
 						return false
 					}
 
-					slices = iSlices
-					ticked = iTicked
-				} else if regexp.MustCompile(`\d+`).MatchString(last) { // "4" = 0/4
-					lastI, err := strconv.Atoi(last)
+					sendClock(ca, guildClockStyle(ca.msg.GuildID), shown)
+					return false
+				}},
+			{
+				aliases: []string{"delete", "del"},
+				help: `delete a clock\n
+				^%Pclock delete name^`,
+				params: []Param{{name: "name", kind: ParamRest}},
+				roles:  []string{"gm"},
+				callback: func(ca CommandArgs) bool {
+					var clocks []*clock
+					var deleted clock
+					err := clockStorage.Update(ca.msg.GuildID, "clocks", &clocks, func(bool) error {
+						cl := findClockExact(clocks, ca.Str("name"))
+						if cl == nil {
+							return errNoClock
+						}
+						deleted = *cl
+						for i, c := range clocks {
+							if c == cl {
+								clocks = append(clocks[:i], clocks[i+1:]...)
+								break
+							}
+						}
+						return nil
+					})
 					if err != nil {
-						SendError(ca, "couldn't parse slice count")
+						sendClockError(ca, err)
 						return false
 					}
-					slices = lastI
-				}
-
-				if cl != nil {
-					// update existing clock
-					cl.Ticked = ticked
-					cl.Slices = slices
-				} else {
-					// create new clock
-					cl = &clock{Name: name, Ticked: ticked, Slices: slices}
-					gset := guildSettings(ca.msg.GuildID)
-					gset.Clocks = append(gset.Clocks, cl)
-				}
-				saveClockSettings()
-			}
-
-			// return clock
-			ctx, err := createClock(guildSettings(ca.msg.GuildID).Style, float64(cl.Slices), float64(cl.Ticked), cl.Name)
-			if err != nil {
-				SendError(ca, fmt.Sprintf("error creating clock: %s", err))
-				return false
-			}
-			ca.sess.ChannelFileSend(ca.msg.ChannelID, fmt.Sprintf("clock_%s.png", time.Now()), writePNG(ctx))
-
-			return false
+					QuickEmbed(ca, QEmbed{content: ca.T("clock.deleted", "name", deleted.Name, "ticked", deleted.Ticked, "slices", deleted.Slices)})
+					return false
+				}},
 		}})
 
 	RegisterCommand(Command{
-		aliases:  []string{"clocks"},
-		help:     `display all clocks`,
-		emptyArg: true,
-		noDM:     true,
+		aliases:   []string{"clocks"},
+		module:    "rpg-clocks",
+		help:      `display all clocks`,
+		emptyArg:  true,
+		noDM:      true,
+		cooldowns: []Cooldown{{scope: CooldownChannel, burst: 3, window: 10 * time.Second}},
 		callback: func(ca CommandArgs) bool {
-			gset := guildSettings(ca.msg.GuildID)
-			if len(gset.Clocks) < 1 {
-				SendError(ca, "no clocks in this guild")
+			clocks := guildClocks(ca.msg.GuildID)
+			style := guildClockStyle(ca.msg.GuildID)
+			if len(clocks) < 1 {
+				SendError(ca, ca.T("clock.none"))
 				return false
 			}
 
 			// display composite
-			ctx, err := createComposite(gset.Clocks, gset.Style)
+			ctx, err := createComposite(clocks, style)
 			if err != nil {
-				SendError(ca, fmt.Sprintf("error creating composite: %s", err))
+				SendError(ca, ca.T("clock.error.create", "err", err))
 				return false
 			}
-			ca.sess.ChannelFileSend(ca.msg.ChannelID, fmt.Sprintf("clock_%s.png", time.Now()), writePNG(ctx))
+			SendFile(ca, fmt.Sprintf("clock_%s.png", time.Now()), writePNG(ctx))
 			return false
 		}})
 }
@@ -0,0 +1,135 @@
+package main
+
+import "testing"
+
+func TestClockCommands(t *testing.T) {
+	type want struct {
+		name           string
+		ticked, slices int
+	}
+	tests := []struct {
+		name     string
+		user     string
+		commands []string
+		clocks   []want
+		err      string
+	}{
+		{name: "create", commands: []string{"!clock create 6 the heist"},
+			clocks: []want{{"the heist", 0, 6}}},
+		{name: "create ticked", commands: []string{"!clock create 2/8 the heist"},
+			clocks: []want{{"the heist", 2, 8}}},
+		{name: "create updates", commands: []string{"!clock create 4 the heist", "!clock create 1/6 the heist"},
+			clocks: []want{{"the heist", 1, 6}}},
+		{name: "tick", commands: []string{"!clock create 4 the heist", "!clock tick 3 The Heist"},
+			clocks: []want{{"the heist", 3, 4}}},
+		{name: "tick clamps", commands: []string{"!clock create 4 the heist", "!clock tick 9 the heist"},
+			clocks: []want{{"the heist", 4, 4}}},
+		{name: "tick clamps to zero", commands: []string{"!clock create 2/4 the heist", "!clock tick -20 the heist"},
+			clocks: []want{{"the heist", 0, 4}}},
+		{name: "tick needs whole name", commands: []string{"!clock create 4 the heist", "!clock tick 1 the he"},
+			clocks: []want{{"the heist", 0, 4}}, err: "clock not found"},
+		{name: "create doesn't replace partial match", commands: []string{"!clock create 4 foobar", "!clock create 2/6 foo"},
+			clocks: []want{{"foobar", 0, 4}, {"foo", 2, 6}}},
+		{name: "delete needs whole name", commands: []string{"!clock create 4 foobar", "!clock delete foo"},
+			clocks: []want{{"foobar", 0, 4}}, err: "clock not found"},
+		{name: "zero slices", commands: []string{"!clock create 0 the heist"},
+			err: "`size` needs at least one slice and no more ticked than slices"},
+		{name: "ticked over slices", commands: []string{"!clock create 5/4 the heist"},
+			err: "`size` needs at least one slice and no more ticked than slices"},
+		{name: "delete", commands: []string{"!clock create 4 the heist", "!clock create 4 escape", "!clock del the heist"},
+			clocks: []want{{"escape", 0, 4}}},
+		{name: "tick missing", commands: []string{"!clock tick 1 nothing"},
+			err: "clock not found"},
+		{name: "delete missing", commands: []string{"!clock delete nothing"},
+			err: "clock not found"},
+		{name: "show missing", commands: []string{"!clock nothing"},
+			err: "clock not found"},
+		{name: "list empty", commands: []string{"!clocks"},
+			err: "no clocks in this guild"},
+		{name: "players can't create", user: testPlayer.ID, commands: []string{"!clock create 4 the heist"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestBot(t)
+			user := testGM
+			if tt.user == testPlayer.ID {
+				user = testPlayer
+			}
+			for _, c := range tt.commands {
+				f.Receive(testTable, user, c)
+			}
+
+			if tt.err != "" {
+				if m := lastSent(t, f, testTable); !isError(m) || embedText(m) != tt.err {
+					t.Fatalf("got %q, want error %q", embedText(m), tt.err)
+				}
+			}
+
+			clocks := guildClocks(testGuild)
+			if len(clocks) != len(tt.clocks) {
+				t.Fatalf("got %d clocks, want %d", len(clocks), len(tt.clocks))
+			}
+			for i, w := range tt.clocks {
+				got := want{clocks[i].Name, clocks[i].Ticked, clocks[i].Slices}
+				if got != w {
+					t.Errorf("clock %d = %+v, want %+v", i, got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestClockImages(t *testing.T) {
+	if _, err := createClock("circle", 4, 1, "test"); err != nil {
+		t.Skip("can't draw clocks here:", err)
+	}
+
+	f := newTestBot(t)
+	f.Receive(testTable, testGM, "!clock create 1/4 the heist")
+	f.Receive(testTable, testGM, "!clockstyle spikes")
+	f.Receive(testTable, testGM, "!clock heist")
+	f.Receive(testTable, testPlayer, "!clocks")
+
+	files := 0
+	for _, m := range f.SentTo(testTable) {
+		if data, ok := f.Files[m.ID]; ok {
+			files++
+			if len(data) < 8 || string(data[1:4]) != "PNG" {
+				t.Errorf("file %d isn't a png", files)
+			}
+		}
+	}
+	if files != 3 {
+		t.Errorf("got %d images, want 3", files)
+	}
+}
+
+func TestFindClock(t *testing.T) {
+	clocks := []*clock{{Name: "foobar"}, {Name: "Foo"}, {Name: "the heist"}}
+	tests := []struct {
+		name         string
+		shown, exact string
+	}{
+		{"foo", "Foo", "Foo"},
+		{"FOOBAR", "foobar", "foobar"},
+		{"foob", "foobar", ""},
+		{"the", "the heist", ""},
+		{"heist", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shown, exact := "", ""
+			if cl := findClock(clocks, tt.name); cl != nil {
+				shown = cl.Name
+			}
+			if cl := findClockExact(clocks, tt.name); cl != nil {
+				exact = cl.Name
+			}
+			if shown != tt.shown || exact != tt.exact {
+				t.Errorf("got %q, %q, want %q, %q", shown, exact, tt.shown, tt.exact)
+			}
+		})
+	}
+}
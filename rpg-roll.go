@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 type probItem struct {
@@ -60,14 +61,34 @@ func (pt *probTable) reduceProb(val int) {
 	pt.renumerate()
 }
 
+// regex for a single roll expression
+var singleRollRx = regexp.MustCompile(`\d*d\d+[b!]?`)
+
+// splitRollTags splits the tags off the end of a roll
+//	tags are the words at the end that start with a letter and aren't dice,
+//	so "d6 d6" is two dice and "2d6 risky standard" is 2d6 tagged "risky standard"
+func splitRollTags(str string) (string, string) {
+	words := strings.Fields(str)
+	n := len(words)
+	for n > 0 && unicode.IsLetter(rune(words[n-1][0])) && !singleRollRx.MatchString(words[n-1]) {
+		n--
+	}
+	return strings.Join(words[:n], " "), strings.Join(words[n:], " ")
+}
+
+var (
+	errNothingToRoll = errors.New("nothing to roll")
+	errRollTooBig    = errors.New("probability too high to compute")
+)
+
 func rollDice(numDice int, diceVal int) ([]int, error) {
 	if numDice < 1 || diceVal <= 1 {
-		return nil, errors.New("nothing to roll")
+		return nil, errNothingToRoll
 	}
 
 	maxProb := math.Pow(float64(diceVal), float64(numDice))
 	if int64(maxProb-1) < 0 {
-		return nil, errors.New("probability too high to compute")
+		return nil, errRollTooBig
 	}
 
 	valSpan := maxProb / float64(diceVal)
@@ -93,6 +114,7 @@ func init() {
 
 	RegisterCommand(Command{
 		aliases: []string{"roll", "r"},
+		module:  "rpg-roll",
 		help: `roll some dice with realistic probability\n
 		^%Proll d6^ - roll a dice with 6 faces
 		^%Proll 2d6^ - roll 2 dice with 6 faces
@@ -105,9 +127,14 @@ func init() {
 		^%Proll gm 2d6^ - roll that only you and the GM can see
 		^%Proll 2d6 risky standard^ - tag a roll's output`,
 		//^%Proll 1dS^ - roll custom dice of name S`,
+		params: []Param{{name: "dice", kind: ParamRest}},
+		cooldowns: []Cooldown{
+			{scope: CooldownUser, burst: 3, window: 10 * time.Second},
+			{scope: CooldownChannel, burst: 8, window: 10 * time.Second},
+		},
 		callback: func(ca CommandArgs) bool {
 			// TO DO: custom die
-			//  	- store in guild storage "roll/dice" scope
+			//  	- store with NewStorage("dice", ScopeGuild, nil)
 			//		- roll as name !roll 2dZ or 2dZoop
 			//		- !setdie name "a" "b" "c"
 			//		- !setface diename facename some string (image attachment)
@@ -117,14 +144,10 @@ func init() {
 			//		- if !set a die, remove emojis for faces that no longer exist (by name or index?)
 			//		- if !setface remove all old emoji -- if no new emoji is given, emoji is removed
 
-			// regex for a single roll expression
-			singleRx := `\d*d\d+[b!]?`
-			singleRxC := regexp.MustCompile(singleRx)
+			str := strings.ToLower(ca.Str("dice"))
 
-			str := strings.ToLower(ca.args)
-
-			if !singleRxC.MatchString(str) {
-				SendError(ca, "no valid roll expressions found")
+			if !singleRollRx.MatchString(str) {
+				SendError(ca, ca.T("roll.error.noexpr"))
 				return false
 			}
 
@@ -136,13 +159,7 @@ func init() {
 			}
 
 			// capture tags and remove if present
-			groups := regexp.MustCompile(`(?:\d|`+singleRx+`)(\s+[\w ]+?$)`).FindAllStringSubmatch(str, -1)
-			tags := ""
-			if len(groups) > 0 && len(groups[0]) > 0 {
-				tags = groups[0][1]
-				str = strings.ReplaceAll(str, tags, "")
-				tags = strings.TrimSpace(tags)
-			}
+			str, tags := splitRollTags(str)
 
 			// replace space with +
 			str = strings.ReplaceAll(str, " ", "+")
@@ -175,11 +192,11 @@ func init() {
 					results += fmt.Sprintf(" *%s* ", expr)
 					continue
 				}
-				if !singleRxC.MatchString(expr) {
+				if !singleRollRx.MatchString(expr) {
 					results += "*" + expr + "*"
 					num, err := strconv.Atoi(expr)
 					if err != nil {
-						SendError(ca, fmt.Sprintf("could not parse modifier: %s", err))
+						SendError(ca, ca.T("roll.error.modifier", "err", err))
 						return false
 					}
 					lastNum = num
@@ -206,19 +223,19 @@ func init() {
 					// split into numDice and diceVal
 					split := strings.Split(expr, "d")
 					if len(split) < 2 {
-						SendError(ca, fmt.Sprintf("not enough parameters in roll syntax"))
+						SendError(ca, ca.T("roll.error.syntax"))
 						return false
 					}
 
 					numDice, err := strconv.Atoi(split[0])
 					if err != nil {
-						SendError(ca, fmt.Sprintf("couldn't parse number of dice: %s", err))
+						SendError(ca, ca.T("roll.error.dice", "err", err))
 						return false
 					}
 
 					diceVal, err := strconv.Atoi(split[1])
 					if err != nil {
-						SendError(ca, fmt.Sprintf("couldn't parse dice value: %s", err))
+						SendError(ca, ca.T("roll.error.faces", "err", err))
 						return false
 					}
 
@@ -229,8 +246,11 @@ func init() {
 						vals = append(vals, num)
 					} else { // roll with ProbTable
 						rollVals, err := rollDice(numDice, diceVal)
-						if err != nil {
-							SendError(ca, err.Error())
+						if err == errRollTooBig {
+							SendError(ca, ca.T("roll.error.toobig"))
+							return false
+						} else if err != nil {
+							SendError(ca, ca.T("roll.error.nothing"))
 							return false
 						}
 						vals = append(vals, rollVals...)
@@ -319,33 +339,40 @@ func init() {
 			results += fmt.Sprintf(" *= **%d***", sum)
 
 			qem := QEmbed{content: results, footer: tags}
-			qem.title = fmt.Sprintf("roll by %s", GetNick(ca.msg.Member))
+			qem.title = ca.T("roll.title", "name", GetNick(ca.msg.Member))
 
 			// handle gm roll
 			if isGMRoll {
 				// find gm in channel
-				gms, err := FindMembersByRole(ca.sess, ca.msg.GuildID, "gm")
+				gms, err := FindMembersWithPermission(ca.sess, ca.msg.GuildID, "gm")
 				if err != nil {
-					SendError(ca, fmt.Sprintf("error finding gm: %s", err))
+					SendError(ca, ca.T("roll.error.gm", "err", err))
 					return false
 				}
 				if len(gms) < 1 {
-					SendError(ca, "no gm found in this server")
+					SendError(ca, ca.T("roll.nogm"))
 					return false
 				}
 
 				// dm the gm
 				chG, err := GetDMChannel(ca.sess, gms[0].User.ID)
 				if err != nil {
-					SendError(ca, fmt.Sprintf("error DMing gm: %s", err))
+					SendError(ca, ca.T("roll.error.dmgm", "err", err))
 					return false
 				}
 				QuickEmbed(CommandArgs{sess: ca.sess, chO: chG.ID}, qem)
 
+				// slash commands can reply privately instead
+				if ca.reply != nil {
+					ca.ephemeral = true
+					QuickEmbed(ca, qem)
+					return false
+				}
+
 				// dm the user
 				chU, err := GetDMChannel(ca.sess, ca.msg.Author.ID)
 				if err != nil {
-					SendError(ca, fmt.Sprintf("error DMing user: %s", err))
+					SendError(ca, ca.T("roll.error.dmuser", "err", err))
 					return false
 				}
 				QuickEmbed(CommandArgs{sess: ca.sess, chO: chU.ID}, qem)
@@ -360,35 +387,36 @@ func init() {
 
 	RegisterCommand(Command{
 		aliases: []string{"seed"},
+		module:  "rpg-roll",
 		help: `display or change random seed\n
 		^%Pseed^ - display current seed
 		^%Pseed asdf^ - change seed to "asdf"`,
-		emptyArg: true,
-		noDM:     true,
-		roles:    []string{"botadmin", "gm"},
+		params: []Param{{name: "seed", kind: ParamRest, optional: true}},
+		noDM:   true,
+		roles:  []string{"botadmin", "gm"},
 		callback: func(ca CommandArgs) bool {
-			if ca.args == "" && ca.alias == "seed" {
-				QuickEmbed(ca, QEmbed{content: fmt.Sprintf("current seed: %v", seedstr)})
+			if !ca.Has("seed") && ca.alias == "seed" {
+				QuickEmbed(ca, QEmbed{content: ca.T("seed.current", "seed", seedstr)})
 				return false
 			}
 			seed = time.Now().UnixNano()
 			footer := ""
-			if ca.args != "" {
-				data := []byte(ca.args)
+			if ca.Has("seed") {
+				data := []byte(ca.Str("seed"))
 				sum := md5.Sum(data)
 				seed = int64(binary.BigEndian.Uint64(sum[:]))
 				seed %= time.Now().UnixNano() // crude attempt to mitigate seed restart manipulation
-				footer = fmt.Sprintf("\"%s\" hashed to numerical value and\nmodulated by time to mitigate manipulation", ca.args)
+				footer = ca.T("seed.hashed", "seed", ca.Str("seed"))
 			}
 			rand.Seed(seed)
 			seedstr = strconv.Itoa(int(seed))
 
-			content := fmt.Sprintf("new seed: %v", seedstr)
+			content := ca.T("seed.new", "seed", seedstr)
 			if footer != "" {
-				QuickEmbed(ca, QEmbed{title: "roll reseeded", content: content, footer: footer})
+				QuickEmbed(ca, QEmbed{title: ca.T("seed.title"), content: content, footer: footer})
 				return false
 			}
-			QuickEmbed(ca, QEmbed{title: "roll reseeded", content: content})
+			QuickEmbed(ca, QEmbed{title: ca.T("seed.title"), content: content})
 			return false
 		},
 	})
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var rollSumRx = regexp.MustCompile(`\*= \*\*(-?\d+)\*\*\*$`)
+
+func TestRoll(t *testing.T) {
+	tests := []struct {
+		args     string
+		min, max int
+		tags     string
+		err      string
+	}{
+		{args: "d6", min: 1, max: 6},
+		{args: "2d6", min: 2, max: 12},
+		{args: "d6 d6", min: 2, max: 12},
+		{args: "d6+d6", min: 2, max: 12},
+		{args: "d6-d6", min: -5, max: 5},
+		{args: "d6-1", min: 0, max: 5},
+		{args: "3d6b", min: 1, max: 6},
+		{args: "2d6 risky standard", min: 2, max: 12, tags: "risky standard"},
+		{args: "d1", min: 1, max: 1},
+		{args: "nothing", err: "no valid roll expressions found"},
+		{args: "2d1", err: "nothing to roll"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			f := newTestBot(t)
+
+			// enough rolls that a wrong range would show up
+			for i := 0; i < 20; i++ {
+				f.Receive(testTable, testPlayer, "!roll "+tt.args)
+				m := lastSent(t, f, testTable)
+
+				if tt.err != "" {
+					if !isError(m) || embedText(m) != tt.err {
+						t.Fatalf("got %q, want error %q", embedText(m), tt.err)
+					}
+					return
+				}
+				if isError(m) {
+					t.Fatalf("unexpected error %q", embedText(m))
+				}
+
+				match := rollSumRx.FindStringSubmatch(embedText(m))
+				if match == nil {
+					t.Fatalf("no sum in %q", embedText(m))
+				}
+				sum, _ := strconv.Atoi(match[1])
+				if sum < tt.min || sum > tt.max {
+					t.Fatalf("sum %d of %q not in %d..%d", sum, embedText(m), tt.min, tt.max)
+				}
+				if tt.tags != "" && (m.Embeds[0].Footer == nil || m.Embeds[0].Footer.Text != tt.tags) {
+					t.Fatalf("footer = %v, want %q", m.Embeds[0].Footer, tt.tags)
+				}
+
+				// cooldowns would stop the loop otherwise
+				cooldownMutex.Lock()
+				cooldownUses = make(map[string][]time.Time)
+				cooldownMutex.Unlock()
+			}
+		})
+	}
+}
+
+func TestRollGM(t *testing.T) {
+	f := newTestBot(t)
+	f.Receive(testTable, testPlayer, "!roll gm 2d6")
+
+	if sent := f.SentTo(testTable); len(sent) > 0 {
+		t.Fatalf("gm roll was sent to the table: %q", embedText(sent[0]))
+	}
+	gm := lastSent(t, f, "dm"+testGM.ID)
+	player := lastSent(t, f, "dm"+testPlayer.ID)
+	if embedText(gm) != embedText(player) {
+		t.Errorf("gm and player got different rolls: %q, %q", embedText(gm), embedText(player))
+	}
+}
+
+func TestRollGMWithoutGM(t *testing.T) {
+	f := newTestBot(t)
+	updateGuildConfig(testGuild, func(gc *guildConfig) {
+		gc.Permissions = map[string]*permRule{"gm": {Users: []string{"nobody"}}}
+	})
+
+	f.Receive(testTable, testPlayer, "!roll gm 2d6")
+	if m := lastSent(t, f, testTable); embedText(m) != "no gm found in this server" {
+		t.Errorf("got %q", embedText(m))
+	}
+}
+
+func TestSplitRollTags(t *testing.T) {
+	tests := []struct {
+		str, dice, tags string
+	}{
+		{"d6", "d6", ""},
+		{"d6 d6", "d6 d6", ""},
+		{"2d6 d8b 3d4!", "2d6 d8b 3d4!", ""},
+		{"d6+2 d6", "d6+2 d6", ""},
+		{"2d6 risky standard", "2d6", "risky standard"},
+		{"d6 d6 desperate", "d6 d6", "desperate"},
+		{"d6-1  controlled ", "d6-1", "controlled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.str, func(t *testing.T) {
+			dice, tags := splitRollTags(tt.str)
+			if dice != tt.dice || tags != tt.tags {
+				t.Errorf("got %q, %q, want %q, %q", dice, tags, tt.dice, tt.tags)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// shards split the bot's guilds between gateway sessions, discord requires
+// them past 2500 guilds
+//	every shard has its own state, so a guild's state is only in the session
+//	of the shard that owns it, see guildTransport
+//	events come from the owning shard, so handlers can use the session they're given
+
+var shardLog = NewLogger("shard")
+
+// how long to wait between identifying shards
+//	discord allows max_concurrency identifies every 5 seconds
+var identifyInterval = 5 * time.Second
+
+var shardMutex sync.RWMutex
+var shardSessions []*discordgo.Session
+
+// shardOf returns which of count shards gets a guild's events
+func shardOf(gid string, count int) int {
+	id, err := strconv.ParseUint(gid, 10, 64)
+	if err != nil || count < 2 {
+		return 0
+	}
+	return int((id >> 22) % uint64(count))
+}
+
+// allShards returns every shard's session in shard order
+func allShards() []*discordgo.Session {
+	shardMutex.RLock()
+	defer shardMutex.RUnlock()
+	return append([]*discordgo.Session{}, shardSessions...)
+}
+
+// guildTransport returns the transport for the shard that owns a guild
+//	or sess if there are no shards, like with FakeTransport
+func guildTransport(sess Transport, gid string) Transport {
+	shards := allShards()
+	if len(shards) == 0 {
+		return sess
+	}
+	return sessionTransport{shards[shardOf(gid, len(shards))]}
+}
+
+// eachShard calls fn with every shard's transport, or just sess if there are no shards
+func eachShard(sess Transport, fn func(Transport)) {
+	shards := allShards()
+	if len(shards) == 0 {
+		fn(sess)
+		return
+	}
+	for _, s := range shards {
+		fn(sessionTransport{s})
+	}
+}
+
+// shardID returns which shard a transport is, 0 if it isn't a gateway session
+func shardID(sess Transport) int {
+	if st, ok := sess.(sessionTransport); ok {
+		return st.ShardID
+	}
+	return 0
+}
+
+// openShards connects a gateway session for every shard
+//	count 0 uses discord's recommended shard count
+//	setup is called on each session before it connects
+func openShards(token string, count int, setup func(*discordgo.Session)) ([]*discordgo.Session, error) {
+	probe, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := 1
+	gb, err := probe.GatewayBot()
+	if err != nil {
+		if count == 0 {
+			return nil, fmt.Errorf("couldn't get recommended shard count: %w", err)
+		}
+		shardLog.Warn("couldn't get gateway info, identifying one shard at a time", "err", err)
+	} else {
+		if count == 0 {
+			count = gb.Shards
+		}
+		if gb.SessionStartLimit.MaxConcurrency > 1 {
+			concurrency = gb.SessionStartLimit.MaxConcurrency
+		}
+		shardLog.Debug("gateway info", "recommended", gb.Shards, "starts_left", gb.SessionStartLimit.Remaining)
+	}
+	if count < 1 {
+		count = 1
+	}
+
+	var sessions []*discordgo.Session
+	for i := 0; i < count; i++ {
+		if i > 0 && i%concurrency == 0 {
+			time.Sleep(identifyInterval)
+		}
+
+		s, err := discordgo.New("Bot " + token)
+		if err != nil {
+			return nil, err
+		}
+		s.ShardID = i
+		s.ShardCount = count
+		setup(s)
+
+		err = s.Open()
+		if err != nil {
+			for _, opened := range sessions {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("couldn't open shard %d: %w", i, err)
+		}
+		sessions = append(sessions, s)
+		shardLog.Info("shard connected", "shard", i, "shards", count)
+	}
+
+	shardMutex.Lock()
+	shardSessions = sessions
+	shardMutex.Unlock()
+	return append([]*discordgo.Session{}, sessions...), nil
+}
+
+// closeShards disconnects every shard
+func closeShards() {
+	for _, s := range allShards() {
+		err := s.Close()
+		if err != nil {
+			shardLog.Warn("error closing shard", "shard", s.ShardID, "err", err)
+		}
+	}
+}
+
+// shardStatus is a shard's health for the stats command and control server
+type shardStatus struct {
+	ID        int
+	Guilds    int
+	Connected bool
+	LatencyMS float64
+}
+
+// shardStatuses returns the status of every shard in shard order
+func shardStatuses() []shardStatus {
+	var out []shardStatus
+	for _, s := range allShards() {
+		s.RLock()
+		connected := s.DataReady
+		s.RUnlock()
+
+		s.State.RLock()
+		guilds := len(s.State.Guilds)
+		s.State.RUnlock()
+
+		out = append(out, shardStatus{
+			ID:        s.ShardID,
+			Guilds:    guilds,
+			Connected: connected,
+			LatencyMS: float64(s.HeartbeatLatency()) / float64(time.Millisecond),
+		})
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// how long shutdown waits before killing ffmpeg and closing the gateway anyway
+var shutdownTimeout = 15 * time.Second
+
+// set once shutdown starts, checked with atomic
+var shuttingDown int32
+
+// closed once shutdown starts, for anything waiting on main
+var shutdownStarted = make(chan bool)
+
+var errShuttingDown = errors.New("shutting down")
+
+// isShuttingDown returns whether new commands should be ignored
+func isShuttingDown() bool {
+	return atomic.LoadInt32(&shuttingDown) == 1
+}
+
+// shutdown stops the bot in order so nothing is left running
+//	- stops accepting commands and closes the control server
+//	- saves settings and music queues
+//	- stops every music session and its ffmpeg
+//	- closes every ButtonizedMessage
+//	- disconnects from voice
+//	- closes every shard's gateway and storage
+//	anything still going after shutdownTimeout is abandoned, and storage is
+//	left open so a save that's still running isn't cut off
+func shutdown(sessions []*discordgo.Session, control *controlServer) {
+	if !atomic.CompareAndSwapInt32(&shuttingDown, 0, 1) {
+		return
+	}
+	close(shutdownStarted)
+	if control != nil {
+		control.Close()
+	}
+
+	done := make(chan bool, 1)
+	go func() {
+		defer recoverGoroutine(sessionTransport{sessions[0]}, "shutdown", "", "")
+
+		saveAllSettings()
+		saveQueues()
+		mainLog.Info("settings saved")
+
+		sessions := musicSessions()
+		for _, ms := range sessions {
+			ms.stopPlayback()
+		}
+		closeAllButtons()
+		for _, ms := range sessions {
+			ms.leaveVoice()
+		}
+		done <- true
+	}()
+
+	finished := true
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		finished = false
+		mainLog.Warn("shutdown timed out", "timeout", shutdownTimeout)
+	}
+
+	if n := killAllFFMPEG(); n > 0 {
+		mainLog.Warn("killed leftover ffmpeg processes", "count", n)
+	}
+	closeShards()
+	// the save above may still be writing, so storage is left for the OS to close
+	if finished {
+		closeStorage()
+	} else {
+		mainLog.Warn("storage not closed, settings may not have saved")
+	}
+	mainLog.Info("shut down")
+}
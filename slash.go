@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var slashLog = NewLogger("slash")
+
+// name of the generated subcommand that runs a parent command's own callback
+//	discord doesn't allow mixing subcommands and options on one command
+var slashDefaultSub = "show"
+
+// interactionReply routes a command's replies to a slash command interaction
+//	the interaction is deferred as soon as it arrives, the first visible reply
+//	replaces the "thinking" message and anything after that is a followup
+type interactionReply struct {
+	sync.Mutex
+	sess    *discordgo.Session
+	i       *discordgo.Interaction
+	replied bool
+}
+
+func (r *interactionReply) send(ms *discordgo.MessageSend, ephemeral bool) (*discordgo.Message, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if !r.replied && !ephemeral {
+		r.replied = true
+		return r.sess.InteractionResponseEdit(r.i, &discordgo.WebhookEdit{Content: &ms.Content, Embeds: &ms.Embeds, Files: ms.Files})
+	}
+
+	params := &discordgo.WebhookParams{Content: ms.Content, Embeds: ms.Embeds, Files: ms.Files}
+	if ephemeral {
+		params.Flags = discordgo.MessageFlagsEphemeral
+	}
+	return r.sess.FollowupMessageCreate(r.i, true, params)
+}
+
+// finish removes the "thinking" message if nothing visible was sent
+func (r *interactionReply) finish() {
+	r.Lock()
+	defer r.Unlock()
+
+	if !r.replied {
+		r.sess.InteractionResponseDelete(r.i)
+	}
+}
+
+func slashOption(p Param) *discordgo.ApplicationCommandOption {
+	opt := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        p.name,
+		Description: ClampStr(p.usage(), 100),
+		Required:    !p.optional,
+	}
+	if p.help != "" {
+		opt.Description = ClampStr(formatTokens(p.help, ""), 100)
+	}
+
+	switch p.kind {
+	case ParamInt:
+		opt.Type = discordgo.ApplicationCommandOptionInteger
+	case ParamFloat:
+		opt.Type = discordgo.ApplicationCommandOptionNumber
+	case ParamUser:
+		opt.Type = discordgo.ApplicationCommandOptionUser
+	case ParamRole:
+		opt.Type = discordgo.ApplicationCommandOptionRole
+	case ParamChannel:
+		opt.Type = discordgo.ApplicationCommandOptionChannel
+	case ParamEnum:
+		for _, c := range p.choices {
+			opt.Choices = append(opt.Choices, &discordgo.ApplicationCommandOptionChoice{Name: c, Value: c})
+		}
+	}
+	if (p.kind == ParamInt || p.kind == ParamFloat) && (p.min != 0 || p.max != 0) {
+		min := p.min
+		opt.MinValue = &min
+		opt.MaxValue = p.max
+	}
+	return opt
+}
+
+// options for a command's own params
+//	commands without params get a single "args" option so they can still be called
+func slashParamOptions(cmd Command) []*discordgo.ApplicationCommandOption {
+	if len(cmd.params) == 0 {
+		if cmd.emptyArg && cmd.callback != nil {
+			return nil
+		}
+		return []*discordgo.ApplicationCommandOption{slashOption(Param{name: "args", kind: ParamRest, optional: acceptsEmpty(&cmd)})}
+	}
+
+	var opts []*discordgo.ApplicationCommandOption
+	for _, p := range cmd.params {
+		opts = append(opts, slashOption(p))
+	}
+	return opts
+}
+
+func slashDescription(cmd Command) string {
+	desc := strings.TrimSpace(strings.Replace(shortHelp(cmd, ""), "\t", "", -1))
+	if desc == "" {
+		desc = cmd.aliases[0]
+	}
+	return ClampStr(desc, 100)
+}
+
+// slashCommand builds an application command from a Command
+func slashCommand(cmd Command) *discordgo.ApplicationCommand {
+	ac := &discordgo.ApplicationCommand{
+		Name:        cmd.aliases[0],
+		Description: slashDescription(cmd),
+	}
+
+	if len(cmd.subcommands) == 0 {
+		ac.Options = slashParamOptions(cmd)
+		return ac
+	}
+
+	if cmd.callback != nil {
+		ac.Options = append(ac.Options, &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        slashDefaultSub,
+			Description: ac.Description,
+			Options:     slashParamOptions(cmd),
+		})
+	}
+	for _, sub := range cmd.subcommands {
+		if sub.hidden {
+			continue
+		}
+		ac.Options = append(ac.Options, &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        sub.aliases[0],
+			Description: slashDescription(sub),
+			Options:     slashParamOptions(sub),
+		})
+	}
+	return ac
+}
+
+// registerSlashCommands overwrites the bot's global application commands with CommandList
+//	hidden commands stay text only
+func registerSlashCommands(sess *discordgo.Session) {
+	var cmds []*discordgo.ApplicationCommand
+	for _, cmd := range CommandList {
+		if cmd.hidden {
+			continue
+		}
+		cmds = append(cmds, slashCommand(cmd))
+	}
+
+	_, err := sess.ApplicationCommandBulkOverwrite(sess.State.User.ID, "", cmds)
+	if err != nil {
+		slashLog.Error("error registering slash commands", "err", err)
+	}
+}
+
+// turns interaction options back into an argument string
+//	so slash commands go through the same param parsing as text commands
+func slashArgs(cmd Command, opts []*discordgo.ApplicationCommandInteractionDataOption) string {
+	byName := make(map[string]*discordgo.ApplicationCommandInteractionDataOption)
+	for _, o := range opts {
+		byName[o.Name] = o
+	}
+
+	params := cmd.params
+	if len(params) == 0 {
+		params = []Param{{name: "args", kind: ParamRest}}
+	}
+
+	var args []string
+	for _, p := range params {
+		o, ok := byName[p.name]
+		if !ok {
+			continue
+		}
+
+		val := ""
+		switch o.Type {
+		case discordgo.ApplicationCommandOptionInteger:
+			val = fmt.Sprintf("%d", o.IntValue())
+		case discordgo.ApplicationCommandOptionNumber:
+			val = fmt.Sprintf("%v", o.FloatValue())
+		case discordgo.ApplicationCommandOptionUser:
+			val = fmt.Sprintf("<@%s>", o.Value)
+		case discordgo.ApplicationCommandOptionRole:
+			val = fmt.Sprintf("<@&%s>", o.Value)
+		case discordgo.ApplicationCommandOptionChannel:
+			val = fmt.Sprintf("<#%s>", o.Value)
+		default:
+			val = o.StringValue()
+			if p.kind != ParamRest && strings.ContainsAny(val, " \t\n") {
+				val = `"` + val + `"`
+			}
+		}
+		args = append(args, val)
+	}
+	return strings.Join(args, " ")
+}
+
+func interactionCreate(sess *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand || isShuttingDown() {
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	var cmd *Command
+	for n, c := range CommandList {
+		if c.aliases[0] == data.Name {
+			cmd = &CommandList[n]
+			break
+		}
+	}
+	if cmd == nil {
+		return
+	}
+
+	// acknowledge straight away, some commands take longer than discord's 3s limit
+	err := sess.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseDeferredChannelMessageWithSource})
+	if err != nil {
+		slashLog.Error("error responding to interaction", "guild", i.GuildID, "channel", i.ChannelID, "err", err)
+		return
+	}
+
+	reply := &interactionReply{sess: sess, i: i.Interaction}
+	defer reply.finish()
+
+	// build a message so callbacks can treat this like any other command
+	author := i.User
+	if i.Member != nil {
+		author = i.Member.User
+		i.Member.GuildID = i.GuildID
+	}
+	m := &discordgo.Message{ChannelID: i.ChannelID, GuildID: i.GuildID, Author: author, Member: i.Member}
+
+	args := slashArgs(*cmd, data.Options)
+	if len(cmd.subcommands) > 0 && len(data.Options) > 0 {
+		sub := data.Options[0]
+		if sub.Name == slashDefaultSub && findSubcommand(cmd, sub.Name) == nil {
+			args = slashArgs(*cmd, sub.Options)
+		} else if s := findSubcommand(cmd, sub.Name); s != nil {
+			args = strings.TrimSpace(sub.Name + " " + slashArgs(*s, sub.Options))
+		}
+	}
+	m.Content = strings.TrimSpace("/" + cmd.aliases[0] + " " + args)
+
+	ca := CommandArgs{sess: sessionTransport{sess}, msg: m, content: m.Content, alias: cmd.aliases[0], reply: reply}
+	defer recoverPanic(ca)
+
+	if !HasAccess(ca.sess, *cmd, m) {
+		SendError(ca, ca.T("slash.noaccess"))
+		return
+	}
+	if !commandEnabled(ca, *cmd) {
+		SendError(ca, ca.T("slash.moduleoff", "module", cmd.module))
+		return
+	}
+	dispatchCommand(ca, *cmd, args)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// bolt backend, storage.db in settingsDir
+//	buckets are namespace > scope > id, with keys and values in the id bucket
+type boltStorage struct {
+	db *bolt.DB
+}
+
+func openBoltStorage() (*boltStorage, error) {
+	path := filepath.Join(settingsDir, "storage.db")
+	// the timeout stops a second bot on the same settings dir hanging forever
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open %s: %w", path, err)
+	}
+	bs := &boltStorage{db: db}
+
+	err = bs.importJSON()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return bs, nil
+}
+
+// importJSON copies namespaces bolt doesn't have yet from their json files
+//	so switching backends keeps existing settings
+func (bs *boltStorage) importJSON() error {
+	js := newJSONStorage()
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		for ns := range storageNamespaces {
+			if tx.Bucket([]byte(ns)) != nil {
+				continue
+			}
+
+			js.Lock()
+			data := js.loadNamespace(ns)
+			js.Unlock()
+
+			nb, err := tx.CreateBucket([]byte(ns))
+			if err != nil {
+				return fmt.Errorf("couldn't create %s bucket: %w", ns, err)
+			}
+			count := 0
+			for scope, ids := range data {
+				sb, err := nb.CreateBucketIfNotExists([]byte(scope))
+				if err != nil {
+					return err
+				}
+				for id, values := range ids {
+					ib, err := sb.CreateBucketIfNotExists([]byte(id))
+					if err != nil {
+						return err
+					}
+					for key, v := range values {
+						err = ib.Put([]byte(key), v)
+						if err != nil {
+							return err
+						}
+						count++
+					}
+				}
+			}
+			if count > 0 {
+				settingsLog.Info("imported json settings into storage.db", "namespace", ns, "values", count)
+			}
+		}
+		return nil
+	})
+}
+
+// returns the id bucket, nil if it doesn't exist and create is false
+func boltBucket(tx *bolt.Tx, create bool, names ...string) (*bolt.Bucket, error) {
+	var b *bolt.Bucket
+	for i, name := range names {
+		var next *bolt.Bucket
+		var err error
+		switch {
+		case i == 0 && create:
+			next, err = tx.CreateBucketIfNotExists([]byte(name))
+		case i == 0:
+			next = tx.Bucket([]byte(name))
+		case create:
+			next, err = b.CreateBucketIfNotExists([]byte(name))
+		default:
+			next = b.Bucket([]byte(name))
+		}
+		if err != nil || next == nil {
+			return nil, err
+		}
+		b = next
+	}
+	return b, nil
+}
+
+func (bs *boltStorage) get(ns string, scope StorageScope, id string, key string) ([]byte, bool, error) {
+	var val []byte
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		b, _ := boltBucket(tx, false, ns, string(scope), id)
+		if b == nil {
+			return nil
+		}
+		// only valid during the transaction
+		if v := b.Get([]byte(key)); v != nil {
+			val = append([]byte{}, v...)
+		}
+		return nil
+	})
+	return val, val != nil, err
+}
+
+func (bs *boltStorage) set(ns string, scope StorageScope, id string, key string, val []byte) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b, err := boltBucket(tx, true, ns, string(scope), id)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), val)
+	})
+}
+
+func (bs *boltStorage) delete(ns string, scope StorageScope, id string, key string) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b, _ := boltBucket(tx, false, ns, string(scope), id)
+		if b == nil {
+			return nil
+		}
+		err := b.Delete([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		// don't leave empty ids around for IDs to list
+		if k, _ := b.Cursor().First(); k == nil {
+			sb, _ := boltBucket(tx, false, ns, string(scope))
+			return sb.DeleteBucket([]byte(id))
+		}
+		return nil
+	})
+}
+
+func (bs *boltStorage) keys(ns string, scope StorageScope, id string) ([]string, error) {
+	var keys []string
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		b, _ := boltBucket(tx, false, ns, string(scope), id)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (bs *boltStorage) ids(ns string, scope StorageScope) ([]string, error) {
+	var ids []string
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		b, _ := boltBucket(tx, false, ns, string(scope))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			// nested buckets have nil values
+			if v == nil {
+				ids = append(ids, string(k))
+			}
+			return nil
+		})
+	})
+	sort.Strings(ids)
+	return ids, err
+}
+
+func (bs *boltStorage) close() error {
+	return bs.db.Close()
+}
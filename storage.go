@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// StorageScope is what a Storage's IDs are
+type StorageScope string
+
+// scopes for Storage
+const (
+	ScopeGuild   StorageScope = "guild"
+	ScopeUser    StorageScope = "user"
+	ScopeChannel StorageScope = "channel"
+)
+
+// Storage keeps JSON values for a module under a guild, user or channel ID
+//	create one per module and scope with NewStorage as a package var
+//	values are marshaled, so Get needs a pointer to the same type Set was given
+type Storage struct {
+	namespace string
+	scope     StorageScope
+}
+
+// storageBackend is where Storage values end up
+//	json (default) keeps each namespace in namespace.json in settingsDir
+//	bolt keeps everything in storage.db
+type storageBackend interface {
+	get(ns string, scope StorageScope, id string, key string) ([]byte, bool, error)
+	set(ns string, scope StorageScope, id string, key string, val []byte) error
+	delete(ns string, scope StorageScope, id string, key string) error
+	keys(ns string, scope StorageScope, id string) ([]string, error)
+	ids(ns string, scope StorageScope) ([]string, error)
+	close() error
+}
+
+// storageConfig is the "storage" section of config.json
+//	- backend is "json" or "bolt", json if empty
+type storageConfig struct {
+	Backend string
+}
+
+func validateStorageConfig(sc storageConfig) error {
+	switch sc.Backend {
+	case "", "json", "bolt":
+		return nil
+	}
+	return fmt.Errorf("unknown storage backend %q, use json or bolt", sc.Backend)
+}
+
+var storage storageBackend
+
+// serializes Storage.Update so read-modify-write is atomic
+var storageUpdateMutex sync.Mutex
+
+// namespaces with a settings file that has older versions to migrate from,
+// anything else gets namespace.json at version 1
+var storageFiles = make(map[string]*settingsFile)
+
+// every namespace a Storage was made for, so bolt can import them from json
+var storageNamespaces = make(map[string]bool)
+
+// NewStorage returns storage for a module's values per guild, user or channel
+//	file, if not nil, is the json backend's settings file for the namespace
+func NewStorage(namespace string, scope StorageScope, file *settingsFile) Storage {
+	storageNamespaces[namespace] = true
+	if file != nil {
+		storageFiles[namespace] = file
+	}
+	return Storage{namespace: namespace, scope: scope}
+}
+
+// openStorage sets up the backend from config.json
+//	called by main once settingsDir is known
+func openStorage(sc storageConfig) error {
+	if sc.Backend == "bolt" {
+		b, err := openBoltStorage()
+		if err != nil {
+			return err
+		}
+		storage = b
+		return nil
+	}
+	storage = newJSONStorage()
+	return nil
+}
+
+func closeStorage() {
+	if storage == nil {
+		return
+	}
+	err := storage.close()
+	if err != nil {
+		settingsLog.Error("error closing storage", "err", err)
+	}
+}
+
+// Get unmarshals the value for id and key into v
+//	returns false if there isn't one
+func (s Storage) Get(id string, key string, v interface{}) (bool, error) {
+	b, ok, err := storage.get(s.namespace, s.scope, id, key)
+	if err != nil || !ok {
+		return false, err
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return false, fmt.Errorf("bad %s value for %s %s: %w", s.namespace, key, id, err)
+	}
+	return true, nil
+}
+
+// Set saves v as the value for id and key
+func (s Storage) Set(id string, key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshaling %s value %s: %w", s.namespace, key, err)
+	}
+	return storage.set(s.namespace, s.scope, id, key, b)
+}
+
+// Delete removes the value for id and key
+func (s Storage) Delete(id string, key string) error {
+	return storage.delete(s.namespace, s.scope, id, key)
+}
+
+// Keys lists the keys with values for id, sorted
+func (s Storage) Keys(id string) ([]string, error) {
+	return storage.keys(s.namespace, s.scope, id)
+}
+
+// IDs lists every guild, user or channel with values, sorted
+func (s Storage) IDs() ([]string, error) {
+	return storage.ids(s.namespace, s.scope)
+}
+
+// Update gets a value into v, calls fn, and saves v if fn doesn't return an error
+//	no other Update runs at the same time, so changes aren't lost
+//	fn is told whether there was a value, v is left as it was if not
+func (s Storage) Update(id string, key string, v interface{}, fn func(found bool) error) error {
+	storageUpdateMutex.Lock()
+	defer storageUpdateMutex.Unlock()
+
+	found, err := s.Get(id, key, v)
+	if err != nil {
+		return err
+	}
+	err = fn(found)
+	if err != nil {
+		return err
+	}
+	return s.Set(id, key, v)
+}
+
+// json backend, one settings file per namespace
+//	{"guild": {"<id>": {"<key>": value}}}
+type jsonStorageData map[StorageScope]map[string]map[string]json.RawMessage
+
+type jsonStorage struct {
+	sync.RWMutex
+	namespaces map[string]jsonStorageData
+}
+
+func newJSONStorage() *jsonStorage {
+	return &jsonStorage{namespaces: make(map[string]jsonStorageData)}
+}
+
+func storageFile(ns string) *settingsFile {
+	f, ok := storageFiles[ns]
+	if !ok {
+		f = &settingsFile{name: ns + ".json", version: 1}
+		storageFiles[ns] = f
+	}
+	return f
+}
+
+// loadNamespace reads a namespace's file the first time it's used
+//	must be called with js locked for writing
+func (js *jsonStorage) loadNamespace(ns string) jsonStorageData {
+	data, ok := js.namespaces[ns]
+	if ok {
+		return data
+	}
+
+	data = make(jsonStorageData)
+	err := storageFile(ns).load(&data)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logLoadError(storageFile(ns).name, err)
+	}
+	if data == nil {
+		data = make(jsonStorageData)
+	}
+	js.namespaces[ns] = data
+	return data
+}
+
+// gets a loaded namespace
+//	called with a read lock, upgrades to load the namespace if needed
+func (js *jsonStorage) namespace(ns string) jsonStorageData {
+	data, ok := js.namespaces[ns]
+	if ok {
+		return data
+	}
+	js.RUnlock()
+	js.Lock()
+	data = js.loadNamespace(ns)
+	js.Unlock()
+	js.RLock()
+	return data
+}
+
+func (js *jsonStorage) get(ns string, scope StorageScope, id string, key string) ([]byte, bool, error) {
+	js.RLock()
+	defer js.RUnlock()
+
+	v, ok := js.namespace(ns)[scope][id][key]
+	return v, ok, nil
+}
+
+func (js *jsonStorage) set(ns string, scope StorageScope, id string, key string, val []byte) error {
+	js.Lock()
+	defer js.Unlock()
+
+	data := js.loadNamespace(ns)
+	if data[scope] == nil {
+		data[scope] = make(map[string]map[string]json.RawMessage)
+	}
+	if data[scope][id] == nil {
+		data[scope][id] = make(map[string]json.RawMessage)
+	}
+	data[scope][id][key] = val
+	return storageFile(ns).save(data)
+}
+
+func (js *jsonStorage) delete(ns string, scope StorageScope, id string, key string) error {
+	js.Lock()
+	defer js.Unlock()
+
+	data := js.loadNamespace(ns)
+	if _, ok := data[scope][id][key]; !ok {
+		return nil
+	}
+	delete(data[scope][id], key)
+	if len(data[scope][id]) == 0 {
+		delete(data[scope], id)
+	}
+	return storageFile(ns).save(data)
+}
+
+func (js *jsonStorage) keys(ns string, scope StorageScope, id string) ([]string, error) {
+	js.RLock()
+	defer js.RUnlock()
+
+	var keys []string
+	for k := range js.namespace(ns)[scope][id] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (js *jsonStorage) ids(ns string, scope StorageScope) ([]string, error) {
+	js.RLock()
+	defer js.RUnlock()
+
+	var ids []string
+	for id := range js.namespace(ns)[scope] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// everything is saved as it's set
+func (js *jsonStorage) close() error {
+	return nil
+}
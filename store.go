@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// where config.json and every other settings file lives
+//	set with -settings or TUSSBOT_SETTINGS, see main
+var settingsDir = "./settings"
+
+// how many previous versions of each settings file are kept, as name.1 to name.N
+var settingsBackups = 5
+
+// settingsFile is a JSON file in settingsDir
+//	- writes go to a temp file that's renamed over the old one,
+//		so a crash mid-write never leaves a half written file
+//	- a lock file stops two bots sharing a settings dir from writing at once
+//	- the last settingsBackups versions are kept, and loaded if the file is corrupt
+//	- unless plain, it's stored as {"version": n, "data": ...}
+//		and older versions are run through migrations when loaded
+//	the data itself isn't locked, callers hold their own mutex
+//	around changing it and saving so it's not marshaled mid-change
+type settingsFile struct {
+	name    string
+	version int
+	plain   bool
+
+	// migrations[n] upgrades data from version n to n+1
+	// files from before versioning are version 0
+	migrations map[int]func(json.RawMessage) (json.RawMessage, error)
+
+	mutex sync.Mutex
+}
+
+type settingsEnvelope struct {
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func (f *settingsFile) path() string {
+	return filepath.Join(settingsDir, f.name)
+}
+
+// load reads the file into v, migrating it if it's an older version
+//	if the file can't be decoded the newest backup that can is used instead
+//	returns an error wrapping os.ErrNotExist if there's no file yet
+func (f *settingsFile) load(v interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	b, err := f.read(f.path())
+	if err != nil {
+		return err
+	}
+	err = f.decode(b, v)
+	if err == nil {
+		return nil
+	}
+
+	for i := 1; i <= settingsBackups; i++ {
+		b, rerr := f.read(fmt.Sprintf("%s.%d", f.path(), i))
+		if rerr != nil {
+			continue
+		}
+		if f.decode(b, v) == nil {
+			settingsLog.Warn("settings file is corrupt, loaded a backup", "file", f.name, "backup", i, "err", err)
+			return nil
+		}
+	}
+	return err
+}
+
+// reads the file, or one of its backups, with the lock held
+func (f *settingsFile) read(path string) ([]byte, error) {
+	unlock, err := lockSettings(f.path()+".lock", false)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't lock %s: %w", f.name, err)
+	}
+	b, err := ioutil.ReadFile(path)
+	unlock()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", filepath.Base(path), err)
+	}
+	return b, nil
+}
+
+// decodes the file's contents into v, migrating and saving them if they're an older version
+//	must be called with f.mutex locked
+func (f *settingsFile) decode(b []byte, v interface{}) error {
+	var err error
+	if f.plain {
+		err = json.Unmarshal(b, v)
+		if err != nil {
+			return fmt.Errorf("JSON error in %s: %w", f.name, err)
+		}
+		return nil
+	}
+
+	version, data, err := unwrapSettings(b)
+	if err != nil {
+		return fmt.Errorf("JSON error in %s: %w", f.name, err)
+	}
+	if version > f.version {
+		return fmt.Errorf("%s is version %d, newer than this bot understands (%d)", f.name, version, f.version)
+	}
+
+	migrated := version < f.version
+	for ; version < f.version; version++ {
+		if migrate, ok := f.migrations[version]; ok {
+			data, err = migrate(data)
+			if err != nil {
+				return fmt.Errorf("couldn't migrate %s from version %d: %w", f.name, version, err)
+			}
+		}
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("JSON error in %s: %w", f.name, err)
+	}
+
+	if migrated {
+		settingsLog.Info("migrated settings file", "file", f.name, "version", f.version)
+		return f.write(v)
+	}
+	return nil
+}
+
+// files written before versioning are just the data
+func unwrapSettings(b []byte) (int, json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(b, &fields) == nil && len(fields) == 2 && fields["version"] != nil && fields["data"] != nil {
+		var env settingsEnvelope
+		err := json.Unmarshal(b, &env)
+		return env.Version, env.Data, err
+	}
+	if !json.Valid(b) {
+		return 0, nil, errors.New("invalid JSON")
+	}
+	return 0, b, nil
+}
+
+// save writes v to the file, keeping the previous version as a backup
+func (f *settingsFile) save(v interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.write(v)
+}
+
+// must be called with f.mutex locked
+func (f *settingsFile) write(v interface{}) error {
+	var b []byte
+	var err error
+	if f.plain {
+		b, err = json.MarshalIndent(v, "", "\t")
+	} else {
+		var data []byte
+		data, err = json.Marshal(v)
+		if err == nil {
+			b, err = json.MarshalIndent(settingsEnvelope{Version: f.version, Data: data}, "", "\t")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error marshaling JSON for %s: %w", f.name, err)
+	}
+
+	err = os.MkdirAll(settingsDir, 0755)
+	if err != nil {
+		return fmt.Errorf("couldn't create settings directory: %w", err)
+	}
+
+	unlock, err := lockSettings(f.path()+".lock", true)
+	if err != nil {
+		return fmt.Errorf("couldn't lock %s: %w", f.name, err)
+	}
+	defer unlock()
+
+	tmp, err := ioutil.TempFile(settingsDir, f.name+".tmp")
+	if err != nil {
+		return fmt.Errorf("error saving %s: %w", f.name, err)
+	}
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error saving %s: %w", f.name, err)
+	}
+
+	f.backup(b)
+
+	err = os.Rename(tmp.Name(), f.path())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error saving %s: %w", f.name, err)
+	}
+	return nil
+}
+
+// shifts name.1 to name.2 and so on, and copies the current file to name.1
+//	the current file is copied rather than moved so it's never missing
+//	nothing is shifted if next is the same as the current file
+func (f *settingsFile) backup(next []byte) {
+	if settingsBackups < 1 {
+		return
+	}
+	old, err := ioutil.ReadFile(f.path())
+	if err != nil || bytes.Equal(old, next) {
+		return
+	}
+	last, err := ioutil.ReadFile(fmt.Sprintf("%s.1", f.path()))
+	if err == nil && bytes.Equal(old, last) {
+		return
+	}
+
+	for i := settingsBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path(), i), fmt.Sprintf("%s.%d", f.path(), i+1))
+	}
+	err = ioutil.WriteFile(fmt.Sprintf("%s.1", f.path()), old, 0644)
+	if err != nil {
+		settingsLog.Warn("couldn't back up settings file", "file", f.name, "err", err)
+	}
+}
+
+// loadAllSettings reads every settings file except config.json and sets up Storage
+//	called by main once settingsDir and config.json are loaded
+func loadAllSettings() error {
+	err := openStorage(Config().Storage)
+	if err != nil {
+		return err
+	}
+	loadGuildConfigs()
+	loadQueues()
+	return nil
+}
+
+// saveAllSettings writes every settings file except config.json and queues.json
+//	Storage is saved as it's set so isn't included
+func saveAllSettings() error {
+	guildConfigMutex.Lock()
+	defer guildConfigMutex.Unlock()
+	return saveGuildConfigs()
+}
+
+// logs a settings file that couldn't be loaded, missing files are expected on first run
+func logLoadError(name string, err error) {
+	if errors.Is(err, os.ErrNotExist) {
+		settingsLog.Warn("no settings file, using empty", "file", name)
+		return
+	}
+	settingsLog.Error("couldn't load settings file", "file", name, "err", err)
+}
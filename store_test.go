@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// points settingsDir at a temp dir for one test
+func tempSettings(t *testing.T) {
+	old := settingsDir
+	settingsDir = t.TempDir()
+	t.Cleanup(func() { settingsDir = old })
+}
+
+func readSettings(t *testing.T, name string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join(settingsDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSettingsFileWrite(t *testing.T) {
+	tempSettings(t)
+	f := &settingsFile{name: "test.json", version: 3}
+
+	if err := f.save(map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	var env settingsEnvelope
+	if err := json.Unmarshal([]byte(readSettings(t, "test.json")), &env); err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]int
+	if err := json.Unmarshal(env.Data, &data); err != nil || env.Version != 3 || data["a"] != 1 {
+		t.Errorf("got version %d, data %s", env.Version, env.Data)
+	}
+
+	// nothing is left behind by the temp file
+	files, _ := ioutil.ReadDir(settingsDir)
+	for _, fi := range files {
+		if strings.Contains(fi.Name(), ".tmp") {
+			t.Errorf("temp file %s left behind", fi.Name())
+		}
+	}
+
+	var got map[string]int
+	if err := f.load(&got); err != nil || got["a"] != 1 {
+		t.Errorf("load = %v, %v", got, err)
+	}
+}
+
+func TestSettingsFileMissing(t *testing.T) {
+	tempSettings(t)
+	f := &settingsFile{name: "test.json", version: 1}
+
+	var v map[string]int
+	if err := f.load(&v); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestSettingsFileBackups(t *testing.T) {
+	tempSettings(t)
+	old := settingsBackups
+	settingsBackups = 3
+	defer func() { settingsBackups = old }()
+
+	f := &settingsFile{name: "test.json", version: 1, plain: true}
+	for i := 1; i <= 5; i++ {
+		if err := f.save(i); err != nil {
+			t.Fatal(err)
+		}
+		// saving the same thing again doesn't push out a backup
+		if err := f.save(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]string{"test.json": "5", "test.json.1": "4", "test.json.2": "3", "test.json.3": "2"}
+	for name, v := range want {
+		if got := readSettings(t, name); got != v {
+			t.Errorf("%s = %q, want %q", name, got, v)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(settingsDir, "test.json.4")); err == nil {
+		t.Error("more backups kept than settingsBackups")
+	}
+}
+
+func TestSettingsFileCorrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		saves   int
+		corrupt []string
+		want    int
+		err     bool
+	}{
+		{name: "file", saves: 3, corrupt: []string{"test.json"}, want: 2},
+		{name: "file and newest backup", saves: 3, corrupt: []string{"test.json", "test.json.1"}, want: 1},
+		{name: "no good backup", saves: 2, corrupt: []string{"test.json", "test.json.1"}, err: true},
+		{name: "no backups", saves: 1, corrupt: []string{"test.json"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempSettings(t)
+			f := &settingsFile{name: "test.json", version: 1}
+			for i := 1; i <= tt.saves; i++ {
+				if err := f.save(i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, name := range tt.corrupt {
+				if err := ioutil.WriteFile(filepath.Join(settingsDir, name), []byte(`{"version": 1, "da`), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var got int
+			err := f.load(&got)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("loaded %d from corrupt files", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("load = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsFileMigration(t *testing.T) {
+	tempSettings(t)
+	f := &settingsFile{name: "test.json", version: 2, migrations: map[int]func(json.RawMessage) (json.RawMessage, error){
+		0: func(data json.RawMessage) (json.RawMessage, error) {
+			var n int
+			err := json.Unmarshal(data, &n)
+			return json.RawMessage(fmt.Sprintf(`{"n": %d}`, n)), err
+		},
+		1: func(data json.RawMessage) (json.RawMessage, error) {
+			var v struct{ N int }
+			err := json.Unmarshal(data, &v)
+			return json.RawMessage(fmt.Sprintf(`{"n": %d, "double": %d}`, v.N, v.N*2)), err
+		},
+	}}
+
+	// from before versioning, just the data
+	if err := ioutil.WriteFile(filepath.Join(settingsDir, "test.json"), []byte("21"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct{ N, Double int }
+	if err := f.load(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.N != 21 || got.Double != 42 {
+		t.Errorf("got %+v", got)
+	}
+
+	// the migrated file is saved, with the old one as a backup
+	var env settingsEnvelope
+	if err := json.Unmarshal([]byte(readSettings(t, "test.json")), &env); err != nil || env.Version != 2 {
+		t.Errorf("saved version %d, %v", env.Version, err)
+	}
+	if readSettings(t, "test.json.1") != "21" {
+		t.Error("unversioned file wasn't backed up")
+	}
+
+	// files from a newer bot aren't touched
+	newer := &settingsFile{name: "test.json", version: 1}
+	if err := newer.load(&got); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("got %v loading a newer version", err)
+	}
+}
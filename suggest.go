@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// how long "did you mean" messages stay up
+var suggestTimeout = 10
+
+// most suggestions shown for an unknown command
+var maxSuggestions = 3
+
+// editDistance returns the levenshtein distance between two strings
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = MinI(MinI(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// visibleCommands returns the commands, or subcommands of parent, that the caller can see
+func visibleCommands(ca CommandArgs, parent *Command) []*Command {
+	list := CommandList
+	if parent != nil {
+		list = parent.subcommands
+	}
+
+	var out []*Command
+	for i, cmd := range list {
+		if cmd.hidden || !HasAccess(ca.sess, cmd, ca.msg) || !commandEnabled(ca, cmd) {
+			continue
+		}
+		if cmd.inChannel != nil && !cmd.inChannel(ca) {
+			continue
+		}
+		out = append(out, &list[i])
+	}
+	return out
+}
+
+// suggestCommands returns visible commands with an alias close to name, closest first
+//	aliases starting with name count as closest
+func suggestCommands(ca CommandArgs, parent *Command, name string) []*Command {
+	// allow more typos in longer names, but never a completely different name
+	maxDist := MinI(ClampI(len(name)/3, 1, 3), len([]rune(name))-1)
+
+	type suggestion struct {
+		cmd  *Command
+		dist int
+	}
+	var found []suggestion
+	for _, cmd := range visibleCommands(ca, parent) {
+		best := -1
+		for _, a := range cmd.aliases {
+			d := editDistance(name, a)
+			if strings.HasPrefix(a, name) {
+				d = 0
+			}
+			if d <= maxDist && (best < 0 || d < best) {
+				best = d
+			}
+		}
+		if best >= 0 {
+			found = append(found, suggestion{cmd, best})
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].dist < found[j].dist
+	})
+	var out []*Command
+	for _, s := range found {
+		out = append(out, s.cmd)
+	}
+	return out
+}
+
+// formats suggestions as "^%Pa^, ^%Pb^ or ^%Pc^"
+func suggestionList(cmds []*Command, gid string) string {
+	if len(cmds) > maxSuggestions {
+		cmds = cmds[:maxSuggestions]
+	}
+
+	var names []string
+	for _, cmd := range cmds {
+		names = append(names, fmt.Sprintf("^%%P%s^", cmd.path))
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return T(gid, "suggest.or", "first", strings.Join(names[:len(names)-1], ", "), "last", names[len(names)-1])
+}
+
+// suggestUnknown replies with the closest commands to an unknown alias
+//	stays silent if nothing is close
+func suggestUnknown(ca CommandArgs, name string) {
+	if name == "" {
+		return
+	}
+	cmds := suggestCommands(ca, nil, name)
+	if len(cmds) == 0 {
+		return
+	}
+	SendErrorTemp(ca, ca.T("suggest.unknown", "command", ClampStr(name, 32), "commands", suggestionList(cmds, ca.guildID())), suggestTimeout)
+}
+
+// FindCommandPartial resolves a command path like FindCommand,
+// but also accepts the start of a name if only one command starts with it
+//	returns close matches if the path can't be resolved
+func FindCommandPartial(ca CommandArgs, path string) (*Command, []*Command) {
+	if cmd, ok := FindCommand(ca, path); ok {
+		return cmd, nil
+	}
+
+	fields := strings.Fields(strings.ToLower(path))
+	if len(fields) < 1 {
+		return nil, nil
+	}
+
+	var parent *Command
+	if len(fields) > 1 {
+		p, ok := FindCommand(ca, strings.Join(fields[:len(fields)-1], " "))
+		if !ok {
+			return nil, nil
+		}
+		parent = p
+	}
+
+	name := fields[len(fields)-1]
+	var starts []*Command
+	for _, cmd := range visibleCommands(ca, parent) {
+		for _, a := range cmd.aliases {
+			if strings.HasPrefix(a, name) {
+				starts = append(starts, cmd)
+				break
+			}
+		}
+	}
+	if len(starts) == 1 {
+		return starts[0], nil
+	}
+	if len(starts) > 1 {
+		return nil, starts
+	}
+	return nil, suggestCommands(ca, parent, name)
+}
@@ -0,0 +1,71 @@
+package main
+
+import "github.com/bwmarrin/discordgo"
+
+// PrivTier is a user's privilege level, each tier can do everything the ones below it can
+type PrivTier int
+
+// privilege tiers
+const (
+	TierUser       PrivTier = iota
+	TierGuildAdmin          // guild owner, or administrator/manage server in the guild
+	TierBotAdmin            // listed in config.json "admins"
+	TierOwner               // listed in config.json "ownerid" or "ownerids"
+)
+
+// Owners returns every owner ID from config.json
+func Owners() []string {
+	conf := Config()
+	owners := append([]string{}, conf.OwnerIDs...)
+	if conf.OwnerID != "" {
+		owners = append(owners, conf.OwnerID)
+	}
+	return owners
+}
+
+// IsOwner checks if a user is a bot owner
+func IsOwner(uid string) bool {
+	for _, id := range Owners() {
+		if id == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBotAdmin checks if a user is a bot-wide admin
+func IsBotAdmin(uid string) bool {
+	for _, id := range Config().Admins {
+		if id == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// IsGuildAdmin checks if a member can administrate their guild
+func IsGuildAdmin(sess Transport, mem *discordgo.Member, chid string) bool {
+	if mem == nil || mem.User == nil {
+		return false
+	}
+	perms := memberPermissions(sess, mem, chid)
+	return hasDiscordPermission(perms, discordgo.PermissionManageServer)
+}
+
+// UserTier returns the privilege tier of a message's author
+//	guild admin only applies to the guild the message was sent in
+func UserTier(sess Transport, msg *discordgo.Message) PrivTier {
+	if msg == nil || msg.Author == nil {
+		return TierUser
+	}
+	if IsOwner(msg.Author.ID) {
+		return TierOwner
+	}
+	if IsBotAdmin(msg.Author.ID) {
+		return TierBotAdmin
+	}
+	if IsGuildAdmin(sess, msg.Member, msg.ChannelID) {
+		return TierGuildAdmin
+	}
+	return TierUser
+}
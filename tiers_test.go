@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestHasAccess(t *testing.T) {
+	users := []*discordgo.User{testOwner, testBotAdm, testAdmin, testGM, testPlayer}
+
+	tests := []struct {
+		name  string
+		cmd   Command
+		rules map[string]*permRule
+		dm    bool
+		// owner, bot admin, guild admin, gm, player
+		want [5]bool
+	}{
+		{name: "anyone", cmd: Command{path: "test"},
+			want: [5]bool{true, true, true, true, true}},
+		{name: "anyone in dm", cmd: Command{path: "test"}, dm: true,
+			want: [5]bool{true, true, true, true, true}},
+		{name: "noDM", cmd: Command{path: "test", noDM: true},
+			want: [5]bool{true, true, true, true, true}},
+		{name: "noDM in dm", cmd: Command{path: "test", noDM: true}, dm: true,
+			want: [5]bool{true, false, false, false, false}},
+		{name: "gm role", cmd: Command{path: "test", roles: []string{"gm"}},
+			want: [5]bool{true, true, false, true, false}},
+		{name: "gm role in dm", cmd: Command{path: "test", roles: []string{"gm"}}, dm: true,
+			want: [5]bool{true, true, false, false, false}},
+		{name: "botadmin role", cmd: Command{path: "test", roles: []string{"botadmin"}},
+			want: [5]bool{true, true, true, false, false}},
+		{name: "gm or botadmin", cmd: Command{path: "test", roles: []string{"gm", "botadmin"}},
+			want: [5]bool{true, true, true, true, false}},
+		{name: "owner tier", cmd: Command{path: "test", tier: TierOwner},
+			want: [5]bool{true, false, false, false, false}},
+		{name: "bot admin tier", cmd: Command{path: "test", tier: TierBotAdmin},
+			want: [5]bool{true, true, false, false, false}},
+		{name: "guild admin tier", cmd: Command{path: "test", tier: TierGuildAdmin},
+			want: [5]bool{true, true, true, false, false}},
+		{name: "guild admin tier in dm", cmd: Command{path: "test", tier: TierGuildAdmin}, dm: true,
+			want: [5]bool{true, true, false, false, false}},
+
+		// guild rules
+		{name: "rule on command", cmd: Command{path: "test"},
+			rules: map[string]*permRule{"test": {Users: []string{testPlayer.ID}}},
+			want:  [5]bool{true, true, false, false, true}},
+		{name: "rule replaces roles", cmd: Command{path: "test", roles: []string{"gm"}},
+			rules: map[string]*permRule{"test": {Roles: []string{testRoleAdmin}}},
+			want:  [5]bool{true, true, true, false, false}},
+		{name: "rule on role node", cmd: Command{path: "test", roles: []string{"gm"}},
+			rules: map[string]*permRule{"gm": {Users: []string{testPlayer.ID}}},
+			want:  [5]bool{true, true, false, false, true}},
+		{name: "rule on botadmin node", cmd: Command{path: "test", roles: []string{"botadmin"}},
+			rules: map[string]*permRule{"botadmin": {Roles: []string{testRoleGM}}},
+			want:  [5]bool{true, true, true, true, false}},
+		{name: "rule on botadmin command", cmd: Command{path: "test", roles: []string{"botadmin"}},
+			rules: map[string]*permRule{"test": {Users: []string{"nobody"}}},
+			want:  [5]bool{true, true, true, false, false}},
+		{name: "rule on gm node for guild admin", cmd: Command{path: "test", roles: []string{"gm"}},
+			rules: map[string]*permRule{"gm": {Users: []string{testGM.ID}}},
+			want:  [5]bool{true, true, false, true, false}},
+		{name: "rule by permission", cmd: Command{path: "test", roles: []string{"gm"}},
+			rules: map[string]*permRule{"gm": {Perms: discordgo.PermissionManageServer}},
+			want:  [5]bool{true, true, true, false, false}},
+		{name: "rule in dm", cmd: Command{path: "test"}, dm: true,
+			rules: map[string]*permRule{"test": {Users: []string{"nobody"}}},
+			want:  [5]bool{true, true, true, true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestBot(t)
+			if tt.rules != nil {
+				updateGuildConfig(testGuild, func(gc *guildConfig) {
+					gc.Permissions = tt.rules
+				})
+			}
+
+			for i, u := range users {
+				msg := &discordgo.Message{Author: u, ChannelID: "dm" + u.ID}
+				if !tt.dm {
+					mem, err := f.State().Member(testGuild, u.ID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					msg = &discordgo.Message{Author: u, ChannelID: testTable, GuildID: testGuild, Member: mem}
+				}
+
+				if got := HasAccess(f, tt.cmd, msg); got != tt.want[i] {
+					t.Errorf("%s: got %v, want %v", u.Username, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Transport is everything the bot uses to talk to discord
+//	sessionTransport wraps a live discordgo session,
+//	FakeTransport in fake-transport_test.go keeps everything in memory so commands can be tested offline
+type Transport interface {
+	// state lookups
+	State() *discordgo.State
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error)
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+
+	// REST and gateway lookups for when state is incomplete, see lookup.go
+	Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error)
+	GuildRoles(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Role, error)
+	GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error)
+	RequestMembers(guildID string, timeout time.Duration) error
+
+	// messages
+	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelFileSend(channelID, name string, r io.Reader, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error
+
+	// reactions
+	MessageReactionAdd(channelID, messageID, emojiID string, options ...discordgo.RequestOption) error
+	MessageReactionRemove(channelID, messageID, emojiID, userID string, options ...discordgo.RequestOption) error
+	MessageReactionsRemoveAll(channelID, messageID string, options ...discordgo.RequestOption) error
+	MessageReactions(messageID string) (<-chan *discordgo.MessageReactionAdd, func())
+
+	// voice and presence
+	ChannelVoiceJoin(gID, cID string, mute, deaf bool) (*discordgo.VoiceConnection, error)
+	ChannelVoiceLeave(vc *discordgo.VoiceConnection) error
+	UpdateGameStatus(idle int, name string) error
+}
+
+// sessionTransport is a Transport over a live gateway session
+type sessionTransport struct {
+	*discordgo.Session
+}
+
+// State returns the session's state cache
+func (t sessionTransport) State() *discordgo.State {
+	return t.Session.State
+}
+
+// MessageReactions returns a channel that gets every reaction added to a message
+//	and a function that stops it, which must be called when done listening
+func (t sessionTransport) MessageReactions(mid string) (<-chan *discordgo.MessageReactionAdd, func()) {
+	ch := make(chan *discordgo.MessageReactionAdd, 10)
+	done := make(chan bool)
+	remove := t.AddHandler(func(_ *discordgo.Session, ev *discordgo.MessageReactionAdd) {
+		if ev.MessageID != mid {
+			return
+		}
+		// handlers run in their own goroutine, don't leave it blocked once stopped
+		select {
+		case ch <- ev:
+		case <-done:
+		}
+	})
+	return ch, func() {
+		remove()
+		close(done)
+	}
+}
+
+// ChannelVoiceLeave disconnects a voice connection
+func (t sessionTransport) ChannelVoiceLeave(vc *discordgo.VoiceConnection) error {
+	return vc.Disconnect()
+}
+
+var memberNonce int64
+
+// RequestMembers asks the gateway for every member of a guild and waits
+// until the last chunk has been added to state
+func (t sessionTransport) RequestMembers(gid string, timeout time.Duration) error {
+	nonce := fmt.Sprintf("members-%d", atomic.AddInt64(&memberNonce, 1))
+	done := make(chan bool)
+	remove := t.AddHandler(func(_ *discordgo.Session, c *discordgo.GuildMembersChunk) {
+		// state has the chunk before handlers are called
+		if c.Nonce == nonce && c.ChunkIndex == c.ChunkCount-1 {
+			close(done)
+		}
+	})
+	defer remove()
+
+	err := t.RequestGuildMembers(gid, "", 0, nonce, false)
+	if err != nil {
+		return fmt.Errorf("couldn't request members: %w", err)
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("timed out waiting for members")
+	}
+}
@@ -18,6 +18,14 @@ func ClampI(num int, min int, max int) int {
 	return num
 }
 
+// MinI returns the smaller of two integers
+func MinI(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 // ClampF a float between two values
 func ClampF(num float64, min float64, max float64) float64 {
 	if num > max {
@@ -39,9 +47,9 @@ func ClampStr(str string, max int) string {
 }
 
 // GetChannelName returns a channel's name or "<unknown channel>"
-func GetChannelName(sess *discordgo.Session, id string) string {
+func GetChannelName(sess Transport, id string) string {
 	channel := "<unknown channel>"
-	ch, err := sess.State.Channel(id)
+	ch, err := lookupChannel(sess, id)
 	if err == nil {
 		channel = ch.Name
 	}
@@ -49,9 +57,8 @@ func GetChannelName(sess *discordgo.Session, id string) string {
 }
 
 // GetRole resolves a role name to object
-func GetRole(sess *discordgo.Session, gid string, name string) (*discordgo.Role, error) {
-	guild, err := sess.State.Guild(gid)
-	roles := guild.Roles
+func GetRole(sess Transport, gid string, name string) (*discordgo.Role, error) {
+	roles, err := lookupRoles(sess, gid)
 	if err != nil {
 		return nil, fmt.Errorf("error getting guild for role %s: %w", name, err)
 	}
@@ -73,7 +80,7 @@ func GetNick(mem *discordgo.Member) string {
 }
 
 // HasRole checks if user has a role by name
-func HasRole(sess *discordgo.Session, mem *discordgo.Member, roleName string) bool {
+func HasRole(sess Transport, mem *discordgo.Member, roleName string) bool {
 	if mem == nil {
 		return false
 	}
@@ -92,19 +99,3 @@ func HasRole(sess *discordgo.Session, mem *discordgo.Member, roleName string) bo
 
 	return false
 }
-
-// FindMembersByRole returns a slice of members in a guild who match a role by name
-func FindMembersByRole(sess *discordgo.Session, gid string, roleName string) ([]*discordgo.Member, error) {
-	guild, err := sess.State.Guild(gid)
-	if err != nil {
-		return nil, fmt.Errorf("couldn't find guild: %w", err)
-	}
-
-	var out []*discordgo.Member
-	for _, m := range guild.Members {
-		if HasRole(sess, m, roleName) {
-			out = append(out, m)
-		}
-	}
-	return out, nil
-}
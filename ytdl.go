@@ -81,7 +81,9 @@ func YTDL(url string) (*SongInfo, error) {
 		"-4", // force ipv4
 	}
 
+	start := time.Now()
 	stdout, err := exec.Command("youtube-dl", args...).Output()
+	ytdlLatency.Observe("", time.Since(start))
 	if err != nil {
 		return nil, fmt.Errorf("error starting youtube-dl process: %w", err)
 	}